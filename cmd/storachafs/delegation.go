@@ -0,0 +1,251 @@
+// cmd/storachafs/delegation.go
+package storachafs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/auth"
+	"github.com/spf13/cobra"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+)
+
+var (
+	delegationAbilities []string
+	delegationExpiresIn time.Duration
+	delegationOutput    string
+	delegationIssued    bool
+)
+
+var delegationCmd = &cobra.Command{
+	Use:   "delegation",
+	Short: "Import, list, create and revoke UCAN delegations",
+}
+
+var delegationImportCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Add delegations to the stored agent's proofs",
+	Long: `Import reads one delegation from each file, as a CAR archive or the base64
+text other Storacha clients print, and keeps it with the proofs of the agent
+stored by login, so later commands can use it without --proof.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		proofs, err := auth.LoadProofs(args...)
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		c, err := store.Client()
+		if err != nil {
+			log.Fatalf("Failed to load agent from %s: %v", store.Path(), err)
+		}
+
+		held := make(map[string]bool)
+		for _, p := range c.Proofs() {
+			held[p.Link().String()] = true
+		}
+		var added []delegation.Delegation
+		for _, p := range proofs {
+			if held[p.Link().String()] {
+				fmt.Printf("  already held: %s\n", p.Link())
+				continue
+			}
+			held[p.Link().String()] = true
+			if p.Audience().DID() != c.DID() {
+				log.Printf("Warning: %s is delegated to %s, not to this agent (%s); it can only serve as evidence", p.Link(), p.Audience().DID(), c.DID())
+			}
+			added = append(added, p)
+			printProof(p)
+		}
+		if err := c.AddProofs(added...); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		fmt.Printf("✅ Imported %d delegations into %s\n", len(added), store.Path())
+	},
+}
+
+var delegationLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the delegations held, or with --issued those issued",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		var proofs []delegation.Delegation
+		if delegationIssued {
+			proofs, err = store.Issued()
+			if err != nil {
+				log.Fatalf("Failed to read issued delegations: %v", err)
+			}
+		} else {
+			data, _, err := store.Load()
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", store.Path(), err)
+			}
+			proofs = data.Delegations
+		}
+		if proofPath != "" {
+			loaded, err := auth.LoadProofs(auth.ProofPaths(proofPath)...)
+			if err != nil {
+				log.Fatalf("Failed to read --proof: %v", err)
+			}
+			proofs = append(proofs, loaded...)
+		}
+		if len(proofs) == 0 {
+			fmt.Println("No delegations")
+			return
+		}
+		for _, p := range proofs {
+			printProof(p)
+		}
+	},
+}
+
+var delegationCreateCmd = &cobra.Command{
+	Use:   "create <audience-did>",
+	Short: "Delegate abilities in a space to another DID",
+	Long: `Create delegates the --can abilities in --space to the audience DID, for
+example upload/list alone for a CI bot that only reads. The delegation is
+issued by the stored agent, or by --private-key with --proof, and carries the
+proofs through which the issuer holds the abilities.
+
+It is written as a CAR archive to --output, or printed as base64 text, and a
+copy is kept so it can be listed with "ls --issued" and revoked later.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		audience, err := did.Parse(args[0])
+		if err != nil {
+			log.Fatalf("Invalid audience DID: %v", err)
+		}
+		if spaceDID == "" {
+			log.Fatalf("Space (--space) is required, or set a default with storachafs space use")
+		}
+		if len(delegationAbilities) == 0 {
+			log.Fatalf("Give the abilities to delegate with --can, e.g. --can upload/list")
+		}
+		var expiration time.Time
+		if delegationExpiresIn > 0 {
+			expiration = time.Now().Add(delegationExpiresIn)
+		}
+
+		c, err := agentClient(email, privateKeyPath, proofPath, debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		d, err := auth.Delegate(c.Issuer(), c.Proofs(), audience, spaceDID, delegationAbilities, expiration)
+		if err != nil {
+			log.Fatalf("Failed to create delegation: %v", err)
+		}
+
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		if _, err := store.SaveIssued(d); err != nil {
+			log.Printf("Failed to keep a copy of the delegation: %v", err)
+		}
+
+		if delegationOutput == "" {
+			text, err := delegation.Format(d)
+			if err != nil {
+				log.Fatalf("Failed to encode delegation: %v", err)
+			}
+			fmt.Println(text)
+			return
+		}
+		raw, err := io.ReadAll(d.Archive())
+		if err != nil {
+			log.Fatalf("Failed to encode delegation: %v", err)
+		}
+		if err := os.WriteFile(delegationOutput, raw, 0600); err != nil {
+			log.Fatalf("Failed to write %s: %v", delegationOutput, err)
+		}
+		fmt.Printf("✅ Delegation %s written to %s\n", d.Link(), delegationOutput)
+		printProof(d)
+	},
+}
+
+var delegationRevokeCmd = &cobra.Command{
+	Use:   "revoke <cid-or-file>",
+	Short: "Revoke a delegation issued earlier",
+	Long: `Revoke asks the service to stop honouring a delegation issued by this agent,
+given by the CID "ls --issued" shows or as a delegation file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		d, err := findIssued(store, args[0])
+		if err != nil {
+			log.Fatalf("Revoke failed: %v", err)
+		}
+
+		c, err := agentClient(email, privateKeyPath, proofPath, debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		if d.Issuer().DID() != c.DID() {
+			log.Fatalf("%s was issued by %s, not by this agent (%s)", d.Link(), d.Issuer().DID(), c.DID())
+		}
+		if err := auth.Revoke(context.Background(), c, d); err != nil {
+			log.Fatalf("Revoke failed: %v", err)
+		}
+		if err := store.RemoveIssued(d.Link().String()); err != nil {
+			log.Printf("Failed to forget the revoked delegation: %v", err)
+		}
+		fmt.Printf("✅ Revoked %s\n", d.Link())
+	},
+}
+
+// findIssued returns the issued delegation with CID arg, or reads it from
+// the file arg
+func findIssued(store *auth.AgentStore, arg string) (delegation.Delegation, error) {
+	if _, err := os.Stat(arg); err == nil {
+		proofs, err := auth.LoadProofs(arg)
+		if err != nil {
+			return nil, err
+		}
+		return proofs[0], nil
+	}
+	issued, err := store.Issued()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range issued {
+		if d.Link().String() == arg {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("no delegation %s among those issued (see storachafs delegation ls --issued)", arg)
+}
+
+func init() {
+	rootCmd.AddCommand(delegationCmd)
+	delegationCmd.AddCommand(delegationImportCmd, delegationLsCmd, delegationCreateCmd, delegationRevokeCmd)
+
+	delegationLsCmd.Flags().BoolVar(&delegationIssued, "issued", false, "list the delegations this agent issued instead")
+	delegationLsCmd.Flags().StringVar(&proofPath, "proof", "", "also list these proof/delegation files, separated by commas")
+
+	delegationCreateCmd.Flags().StringArrayVar(&delegationAbilities, "can", nil, "ability to delegate, e.g. upload/list or space/blob/add (repeatable)")
+	delegationCreateCmd.Flags().StringVar(&spaceDID, "space", "", "space to delegate abilities in, by DID or name (default: the space chosen with space use)")
+	delegationCreateCmd.Flags().DurationVar(&delegationExpiresIn, "expires-in", 0, "how long the delegation is valid, e.g. 720h (default: never expires)")
+	delegationCreateCmd.Flags().StringVarP(&delegationOutput, "output", "o", "", "write the delegation as a CAR archive to this file instead of printing it")
+
+	for _, c := range []*cobra.Command{delegationCreateCmd, delegationRevokeCmd} {
+		c.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+		c.Flags().StringVar(&email, "email", "", "email for email-based authentication")
+		c.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
+		c.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file, or several separated by commas")
+	}
+}
@@ -0,0 +1,78 @@
+// cmd/storachafs/importflags.go
+package storachafs
+
+import (
+	"fmt"
+
+	"github.com/ABD-AZE/StorachaFS/internal/cache"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+// UnixFS import settings shared by every command that uploads content
+var (
+	chunkerSpec string
+	dagLayout   string
+	rawLeaves   bool
+	cidVersion  int
+	maxLinks    int
+	shardSize   string
+)
+
+func addImportFlags(cmd *cobra.Command) {
+	defaults := unixfs.DefaultParams()
+	cmd.Flags().StringVar(&chunkerSpec, "chunker", defaults.Chunker, "file chunker: size-<bytes>, rabin-<min>-<avg>-<max> or buzhash")
+	cmd.Flags().StringVar(&dagLayout, "layout", defaults.Layout, "file DAG layout: balanced or trickle")
+	cmd.Flags().BoolVar(&rawLeaves, "raw-leaves", defaults.RawLeaves, "store file chunks as raw blocks (requires CIDv1)")
+	cmd.Flags().IntVar(&cidVersion, "cid-version", defaults.CIDVersion, "CID version of imported content (0 or 1)")
+	cmd.Flags().IntVar(&maxLinks, "max-links", defaults.MaxLinks, "maximum number of links per file DAG node")
+	cmd.Flags().StringVar(&shardSize, "shard-size", "127MiB", "maximum size of each CAR shard stored in the space")
+}
+
+// uploadShardSize parses --shard-size
+func uploadShardSize() (int64, error) {
+	n, err := cache.ParseSize(shardSize)
+	if err != nil {
+		return 0, fmt.Errorf("--shard-size: %v", err)
+	}
+	if n > upload.DefaultShardSize {
+		return 0, fmt.Errorf("--shard-size: at most %d bytes are accepted per shard", upload.DefaultShardSize)
+	}
+	return n, nil
+}
+
+// importParams returns the import settings for source: those recorded by its
+// last upload if any, overridden by the flags given on this run
+func importParams(cmd *cobra.Command, source string) (unixfs.Params, error) {
+	p := unixfs.DefaultParams()
+	if source != "" {
+		recorded, ok, err := unixfs.LoadParams(unixfs.ParamsPath(source))
+		if err != nil {
+			return unixfs.Params{}, err
+		}
+		if ok {
+			p = recorded
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("chunker") {
+		p.Chunker = chunkerSpec
+	}
+	if flags.Changed("layout") {
+		p.Layout = dagLayout
+	}
+	if flags.Changed("cid-version") {
+		p.CIDVersion = cidVersion
+		// like kubo, raw leaves follow the CID version unless asked for explicitly
+		p.RawLeaves = cidVersion > 0
+	}
+	if flags.Changed("raw-leaves") {
+		p.RawLeaves = rawLeaves
+	}
+	if flags.Changed("max-links") {
+		p.MaxLinks = maxLinks
+	}
+	return p, p.Validate()
+}
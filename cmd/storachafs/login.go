@@ -0,0 +1,94 @@
+// cmd/storachafs/login.go
+package storachafs
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ABD-AZE/StorachaFS/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login --email <email>",
+	Short: "Log in to a Storacha account by email",
+	Long: `Login sends a confirmation link to --email and waits until it is clicked.
+The agent key and the delegations the account grants it are then stored in
+the user config directory, so later commands given the same --email do not ask
+again until the delegations expire. A login that is still valid is reused.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		account, err := auth.AccountDID(email)
+		if err != nil {
+			log.Fatalf("Login failed: %v", err)
+		}
+		c, err := auth.EmailAuth(email)
+		if err != nil {
+			log.Fatalf("Login failed: %v", err)
+		}
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Login failed: %v", err)
+		}
+		fmt.Printf("✅ Logged in as %s\n", account)
+		fmt.Printf("Agent: %s\n", c.DID())
+		fmt.Printf("Credentials stored in %s\n", store.Path())
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored agent key and delegations",
+	Long: `Logout deletes the stored agent key, the delegations it holds and those it
+issued, and forgets the default space chosen with "space use".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Logout failed: %v", err)
+		}
+		removed, err := store.Remove()
+		if err != nil {
+			log.Fatalf("Logout failed: %v", err)
+		}
+		// the default space was chosen among the logged out agent's spaces
+		if err := clearDefaultSpace(); err != nil {
+			log.Fatalf("Logout failed: %v", err)
+		}
+		if !removed {
+			fmt.Println("Not logged in")
+			return
+		}
+		fmt.Printf("✅ Logged out, removed %s\n", store.Path())
+	},
+}
+
+// clearDefaultSpace removes the space chosen with "space use" from config.json
+func clearDefaultSpace() error {
+	path, err := auth.DefaultConfigPath()
+	if err != nil {
+		return err
+	}
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if config.Space == "" {
+		return nil
+	}
+	config.Space = ""
+	if err := config.Save(path); err != nil {
+		return err
+	}
+	fmt.Println("Cleared the default space")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	loginCmd.Flags().StringVar(&email, "email", "", "email of the account to log in to")
+	if err := loginCmd.MarkFlagRequired("email"); err != nil {
+		panic(err)
+	}
+}
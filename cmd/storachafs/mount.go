@@ -6,30 +6,21 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"strings"
 	"time"
 
 	"github.com/ABD-AZE/StorachaFS/internal/auth"
 	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/upload"
 	"github.com/hanwen/go-fuse/v2/fs"
 	fusefs "github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/spf13/cobra"
 
 	// UCAN / DID / signer
-	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/did"
-	"github.com/storacha/go-ucanto/principal"
-	"github.com/storacha/go-ucanto/principal/ed25519/signer"
-	guppyDelegation "github.com/storacha/guppy/pkg/delegation"
 
 	// Guppy client
 	"github.com/storacha/guppy/pkg/client"
-
-	// CAR + CID + multihash
-	ipfscid "github.com/ipfs/go-cid"
-	carv2 "github.com/ipld/go-car/v2"
-	"github.com/multiformats/go-multihash"
 )
 
 var (
@@ -43,6 +34,9 @@ var (
 	proofPath      string
 	spaceDID       string
 	readOnly       bool
+	includeHidden  bool
+	noCache        bool
+	offline        bool
 )
 
 var mountCmd = &cobra.Command{
@@ -56,6 +50,14 @@ var mountCmd = &cobra.Command{
 		if (cid == "" && sourcePath == "") || (cid != "" && sourcePath != "") {
 			log.Fatalf("You must specify exactly one of --cid (to mount existing content) or --source (to upload and mount local directory)")
 		}
+		if offline && (sourcePath != "" || noCache) {
+			log.Fatalf("--offline serves --cid content from the local block cache; it cannot be combined with --source or --no-cache")
+		}
+
+		params, err := importParams(cmd, sourcePath)
+		if err != nil {
+			log.Fatalf("Import settings error: %v", err)
+		}
 
 		// Create mount point if it doesn't exist
 		if err := os.MkdirAll(mnt, 0755); err != nil {
@@ -63,10 +65,13 @@ var mountCmd = &cobra.Command{
 		}
 
 		var finalCID string
+		authMethod := "none"
+		var authConfig *auth.AuthConfig
 
 		// Determine authentication method and validate
 		if !readOnly {
-			authMethod, err := auth.GetAuthMethodFromArgs(email, privateKeyPath, proofPath, spaceDID)
+			var err error
+			authMethod, err = auth.GetAuthMethodFromArgs(email, privateKeyPath, proofPath, spaceDID)
 			if err != nil {
 				log.Fatalf("Authentication error: %v", err)
 			}
@@ -77,7 +82,6 @@ var mountCmd = &cobra.Command{
 			case "private_key":
 				log.Println("Using private key authentication...")
 				// Validate private key authentication
-				var authConfig *auth.AuthConfig
 				if privateKeyPath != "" && proofPath != "" && spaceDID != "" {
 					authConfig = auth.LoadAuthConfigFromFlags(privateKeyPath, proofPath, spaceDID)
 				} else {
@@ -90,6 +94,18 @@ var mountCmd = &cobra.Command{
 					log.Fatalf("Authentication validation failed: %v", err)
 				}
 			case "none":
+				// the stored login only makes a mount writable when the space
+				// is named, so mounting a CID never publishes to the default
+				// space unasked
+				if hasStoredLogin() && cmd.Flags().Changed("space") {
+					authMethod = "stored"
+					log.Println("Using the stored login...")
+					break
+				}
+				if hasStoredLogin() {
+					log.Println("No --space given - mounting in read-only mode; pass --space to write with the stored login")
+					break
+				}
 				log.Println("No authentication provided - mounting in read-only mode")
 				log.Println("For write operations, provide authentication via:")
 				log.Println("  --email for email auth, or")
@@ -112,20 +128,49 @@ var mountCmd = &cobra.Command{
 			}
 
 			log.Printf("Packing and uploading directory: %s", sourcePath)
-			root, err := uploadDirectoryWithAuth(sourcePath, email, privateKeyPath, proofPath, spaceDID, debug)
+			res, err := uploadDirectoryWithAuth(sourcePath, email, privateKeyPath, proofPath, spaceDID, params, debug)
 			if err != nil {
-				log.Fatalf("Failed to upload directory: %v", err)
+				log.Fatalf("Failed to upload directory: %v (run `storachafs upload --resume %s` to continue)", err, sourcePath)
+			}
+			if err := params.Save(unixfs.ParamsPath(sourcePath)); err != nil {
+				log.Printf("Failed to record import settings: %v", err)
 			}
-			log.Printf("✓ Directory uploaded with root CID: %s", root)
-			finalCID = root
+			reportUpload(res)
+			finalCID = res.Root.String()
 		} else {
 			// Use provided CID directly
 			finalCID = cid
 			log.Printf("Mounting existing content with CID: %s", finalCID)
 		}
 
+		clientConfig, err := newClientConfig(!noCache)
+		if err != nil {
+			log.Fatalf("Block cache error: %v", err)
+		}
+		if offline && clientConfig.Cache == nil {
+			log.Fatalf("--offline serves content from the local block cache; it cannot be combined with --cache-size 0")
+		}
+		clientConfig.Offline = offline
+		clientConfig.Import = params
+
+		writable := false
+		if !readOnly && authMethod != "none" && !offline && spaceDID == "" {
+			log.Println("No --space given - mounting in read-only mode")
+		} else if !readOnly && authMethod != "none" && !offline {
+			stagingDir, err := enableWrites(clientConfig, authMethod, authConfig)
+			if err != nil {
+				log.Fatalf("Cannot enable writes: %v", err)
+			}
+			defer func() { _ = os.RemoveAll(stagingDir) }()
+			writable = true
+		}
+
 		// Create filesystem
-		root := fuse.NewStorachaFS(finalCID, debug)
+		root := fuse.NewStorachaFS(finalCID, clientConfig)
+
+		if offline {
+			reportOfflineAvailability(root.Client(), finalCID)
+		}
 
 		opts := &fs.Options{
 			MountOptions: fusefs.MountOptions{
@@ -141,10 +186,10 @@ var mountCmd = &cobra.Command{
 			log.Fatalf("mount: %v", err)
 		}
 
-		if readOnly {
-			log.Printf("✓ Mounted %s at %s (read-only)", finalCID, mnt)
-		} else {
+		if writable {
 			log.Printf("✓ Mounted %s at %s (authenticated - read/write)", finalCID, mnt)
+		} else {
+			log.Printf("✓ Mounted %s at %s (read-only)", finalCID, mnt)
 		}
 		server.Wait()
 	},
@@ -161,269 +206,197 @@ func init() {
 
 	mountCmd.Flags().StringVar(&email, "email", "", "email for email-based authentication")
 	mountCmd.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
-	mountCmd.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file")
-	mountCmd.Flags().StringVar(&spaceDID, "space", "", "space DID to interact with (required for uploads)")
+	mountCmd.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file, or several separated by commas")
+	mountCmd.Flags().StringVar(&spaceDID, "space", "", "space to interact with, by DID or name (required for uploads; default: the space chosen with space use)")
 	mountCmd.Flags().BoolVar(&readOnly, "read-only", false, "mount in read-only mode (no authentication)")
+	mountCmd.Flags().BoolVar(&includeHidden, "hidden", false, "include files and directories starting with \".\" when uploading --source")
+	mountCmd.Flags().BoolVar(&noCache, "no-cache", false, "do not persist fetched blocks on disk")
+	mountCmd.Flags().BoolVar(&offline, "offline", false, "serve the mount entirely from the local block cache without network access")
+	addRetrievalFlags(mountCmd)
+	addImportFlags(mountCmd)
 }
 
-// uploadDirectoryWithAuth: pack dir to CAR, store shard with StoreAdd, then UploadAdd to register upload.
-// Accepts either email interactive auth (requires user to authenticate via RequestAccess/CLI flow) OR
-// requires private-key + proof for non-interactive auth. For simplicity this implementation prefers private-key+proof.
-func uploadDirectoryWithAuth(localPath, emailArg, privateKeyPathArg, proofPathArg, spaceDIDStr string, debug bool) (string, error) {
-	ctx := context.Background()
-
-	// validate
-	if localPath == "" {
-		return "", fmt.Errorf("localPath required")
-	}
-
-	// parse space
-	space, err := did.Parse(spaceDIDStr)
+// enableWrites makes the mount writable by publishing changes to --space with
+// the authenticated client; it returns the staging directory to clean up on unmount
+func enableWrites(config *fuse.ClientConfig, authMethod string, authConfig *auth.AuthConfig) (string, error) {
+	space, err := did.Parse(spaceDID)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse space DID '%s': %v", spaceDIDStr, err)
+		return "", fmt.Errorf("failed to parse space DID '%s': %v", spaceDID, err)
 	}
 
-	// load signer + proofs (preferred path for programmatic upload)
-	var issuer principal.Signer
-	var proofs []delegation.Delegation
-	if privateKeyPathArg != "" && proofPathArg != "" {
-		privBytes, err := os.ReadFile(privateKeyPathArg)
-		if err != nil {
-			return "", fmt.Errorf("read private key: %v", err)
-		}
-		issuer, err = signer.Parse(strings.TrimSpace(string(privBytes)))
-		if err != nil {
-			return "", fmt.Errorf("parse private key: %v", err)
-		}
-
-		prfBytes, err := os.ReadFile(proofPathArg)
-		if err != nil {
-			return "", fmt.Errorf("read proof file: %v", err)
-		}
-		proof, err := guppyDelegation.ExtractProof(prfBytes)
-		if err != nil {
-			return "", fmt.Errorf("extract proofs: %v", err)
-		}
-		proofs = []delegation.Delegation{proof}
-	} else if emailArg != "" {
-		// Interactive flow: use email auth but we need issuer & proofs for the upload API
-		if debug {
-			log.Printf("Using email authentication for upload (interactive)")
-		}
-		guppyClient, err := auth.EmailAuth(emailArg)
-		if err != nil {
-			return "", fmt.Errorf("email auth failed: %v", err)
-		}
-
-		// For email auth, we need to extract the issuer and proofs from the authenticated client
-		// This is a workaround since the upload APIs expect issuer + proofs directly
-		// In practice, you might want to use the client's upload methods directly if available
-
-		// Pack directory to CAR (shell out)
-		carPath, err := packDirectoryToCAR(localPath, debug)
-		if err != nil {
-			return "", fmt.Errorf("packDirectoryToCAR: %v", err)
-		}
-		defer func() { _ = os.Remove(carPath) }()
-
-		// Get root CID from CAR
-		rootCid, err := carRootCIDFromFile(carPath)
-		if err != nil {
-			return "", fmt.Errorf("get car root cid: %v", err)
-		}
-
-		// Use the authenticated guppy client to upload the CAR file directly
-		carFile, err := os.Open(carPath)
-		if err != nil {
-			return "", fmt.Errorf("open car file: %v", err)
-		}
-		defer func() { _ = carFile.Close() }()
-
-		// Use SpaceBlobAdd method for email-authenticated uploads
-		// NOTE: SpaceBlobAdd uploads the CAR file to the space and returns the space's CID for this content
-		blobCid, _, err := guppyClient.SpaceBlobAdd(ctx, carFile, space)
-		if err != nil {
-			return "", fmt.Errorf("SpaceBlobAdd failed: %v", err)
-		}
-
-		if debug {
-			log.Printf("Email-based upload completed. UnixFS root: %s, Space blob CID: %s", rootCid.String(), blobCid.String())
-		}
-
-		// CRITICAL: Return the space's blob CID, not the UnixFS root CID
-		return blobCid.String(), nil
-	} else {
-		return "", fmt.Errorf("provide --private-key and --proof for programmatic upload")
+	var c *client.Client
+	switch authMethod {
+	case "email":
+		c, err = auth.EmailAuth(email)
+	case "private_key":
+		c, err = auth.PrivateKeyAuth(authConfig)
+	case "stored":
+		c, err = agentClient("", "", "", debug)
 	}
-
-	// pack directory to CAR (shell out)
-	carPath, err := packDirectoryToCAR(localPath, debug)
 	if err != nil {
-		return "", fmt.Errorf("packDirectoryToCAR: %v", err)
+		return "", err
 	}
-	// make sure temp file cleaned up
-	defer func() { _ = os.Remove(carPath) }()
-
-	// read CAR bytes (docs example uses full bytes; for large CARs prefer streaming)
-	carBytes, err := os.ReadFile(carPath)
-	if err != nil {
-		return "", fmt.Errorf("read car file: %v", err)
+	if c == nil {
+		return "", fmt.Errorf("%s authentication did not produce a client", authMethod)
 	}
 
-	// compute CAR multihash (sha2-256)
-	mh, err := multihash.Sum(carBytes, multihash.SHA2_256, -1)
+	stagingDir, err := os.MkdirTemp("", "storachafs-staging-*")
 	if err != nil {
-		return "", fmt.Errorf("multihash sum: %v", err)
+		return "", fmt.Errorf("create staging directory: %v", err)
 	}
-	// build CAR shard CID (cidv1, codec 0x0202 per docs)
-	shardCid := ipfscid.NewCidV1(0x0202, mh) // 0x0202 = CAR codec
+	config.Publisher = upload.NewSpace(c, space, debug)
+	config.StagingDir = stagingDir
+	return stagingDir, nil
+}
 
-	// read root CID(s) from CAR using go-car
-	rootCid, err := carRootCIDFromFile(carPath)
+// reportOfflineAvailability logs how many paths of an offline mount can be
+// served in full from the cache, listing them with --debug; it exits if not
+// even the root listing is cached
+func reportOfflineAvailability(client fuse.StorachaClient, rootCID string) {
+	if _, err := client.ListDir(rootCID); err != nil {
+		log.Fatalf("Cannot mount %s offline, its root directory is not in the block cache: %v", rootCID, err)
+	}
+	statuses, err := client.Availability(rootCID)
 	if err != nil {
-		return "", fmt.Errorf("get car root cid: %v", err)
+		log.Fatalf("Failed to check offline availability: %v", err)
 	}
 
-	if debug {
-		log.Printf("CAR root: %s ; shard CID: %s ; size: %d bytes", rootCid.String(), shardCid.String(), len(carBytes))
+	complete := 0
+	for _, st := range statuses {
+		mark := "✗"
+		if st.Complete {
+			mark = "✓"
+			complete++
+		}
+		if debug {
+			p := st.Path
+			if st.Dir && p != "/" {
+				p += "/"
+			}
+			log.Printf("  %s %s", mark, p)
+		}
 	}
+	log.Printf("Offline: %d of %d known paths are fully available; uncached blocks will fail with ENODATA", complete, len(statuses))
+}
 
-	// Create a Guppy client with the issuer and proofs
-	guppyClient, err := client.NewClient(client.WithPrincipal(issuer))
-	if err != nil {
-		return "", fmt.Errorf("failed to create guppy client: %v", err)
-	}
+// uploadDirectoryWithAuth imports localPath and uploads it to the space in
+// bounded-size CAR shards, then registers its index and the upload itself.
+func uploadDirectoryWithAuth(localPath, emailArg, privateKeyPathArg, proofPathArg, spaceDIDStr string, params unixfs.Params, debug bool) (upload.Result, error) {
+	ctx := context.Background()
 
-	// Add proofs to the client
-	if err := guppyClient.AddProofs(proofs...); err != nil {
-		return "", fmt.Errorf("failed to add proofs to client: %v", err)
+	// validate
+	if localPath == "" {
+		return upload.Result{}, fmt.Errorf("localPath required")
 	}
-
-	// Use SpaceBlobAdd to upload the CAR file
-	carFile, err := os.Open(carPath)
+	info, err := os.Stat(localPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open CAR file: %v", err)
+		return upload.Result{}, fmt.Errorf("stat source dir: %v", err)
 	}
-	defer func() { _ = carFile.Close() }()
-
-	// Upload the CAR using SpaceBlobAdd
-	// NOTE: SpaceBlobAdd uploads the CAR file to the space and returns the space's CID for this content
-	blobMultihash, _, err := guppyClient.SpaceBlobAdd(ctx, carFile, space)
-	if err != nil {
-		return "", fmt.Errorf("SpaceBlobAdd failed: %v", err)
+	if !info.IsDir() {
+		return upload.Result{}, fmt.Errorf("source not a directory")
 	}
 
-	actualCid := ipfscid.NewCidV1(0x55, blobMultihash) // 0x55 = raw codec
-	if debug {
-		log.Printf("CAR uploaded to space. UnixFS root: %s, Space blob CID: %s", rootCid.String(), actualCid.String())
+	space, err := uploadSpace(emailArg, privateKeyPathArg, proofPathArg, spaceDIDStr, debug)
+	if err != nil {
+		return upload.Result{}, err
 	}
-	return actualCid.String(), nil
-	// CRITICAL: Return the space's blob CID, not the UnixFS root CID
-	// The space blob CID is what exists in the space DAG and can be mounted
-	// The UnixFS root CID is internal to the CAR file and not directly accessible
+	return space.UploadDirectory(ctx, localPath, unixfs.DirOptions{Params: params, Hidden: includeHidden})
 }
 
-// packDirectoryToCAR: tries `npx ipfs-car pack <dir> --output <tmp>` first, falls back to `car create` (go-car) for directory packing
-// Returns path to temp CAR file.
-func packDirectoryToCAR(srcDir string, debug bool) (string, error) {
-	// ensure directory
-	info, err := os.Stat(srcDir)
+// uploadSpace authenticates for uploads to spaceDIDStr, with the client
+// agentClient picks
+func uploadSpace(emailArg, privateKeyPathArg, proofPathArg, spaceDIDStr string, debug bool) (*upload.Space, error) {
+	// parse space
+	space, err := did.Parse(spaceDIDStr)
 	if err != nil {
-		return "", fmt.Errorf("stat source dir: %v", err)
-	}
-	if !info.IsDir() {
-		return "", fmt.Errorf("source not a directory")
+		return nil, fmt.Errorf("failed to parse space DID '%s': %v", spaceDIDStr, err)
 	}
 
-	tmp, err := os.CreateTemp("", "storacha-*.car")
+	guppyClient, err := agentClient(emailArg, privateKeyPathArg, proofPathArg, debug)
 	if err != nil {
-		return "", fmt.Errorf("create tmp file: %v", err)
+		return nil, err
 	}
-	tmpPath := tmp.Name()
-	if err := tmp.Close(); err != nil {
-		return "", fmt.Errorf("close temp file: %v", err)
+
+	maxShard, err := uploadShardSize()
+	if err != nil {
+		return nil, err
 	}
+	return upload.NewSpace(guppyClient, space, debug).WithShardSize(maxShard), nil
+}
 
-	// try ipfs-car (Node.js tool) - primary choice for directories
-	if path, e := exec.LookPath("npx"); e == nil && path != "" {
-		cmd := exec.Command("npx", "ipfs-car", "pack", srcDir, "--output", tmpPath)
-		if debug {
-			log.Printf("Running: %s", strings.Join(cmd.Args, " "))
-		}
-		out, err := cmd.CombinedOutput()
-		if err == nil {
-			if debug {
-				log.Printf("ipfs-car output: %s", string(out))
-			}
-			return tmpPath, nil
-		}
-		if debug {
-			log.Printf("ipfs-car failed: %v out=%s", err, string(out))
+// agentClient returns a client for invoking the service. Accepts either
+// private-key + proof for non-interactive auth, email interactive auth
+// (requires user to authenticate via RequestAccess/CLI flow), or else the
+// agent stored by a previous login.
+func agentClient(emailArg, privateKeyPathArg, proofPathArg string, debug bool) (*client.Client, error) {
+	// load signer + proofs (preferred path for programmatic upload)
+	if privateKeyPathArg != "" && proofPathArg != "" {
+		issuer, err := auth.LoadPrivateKey(privateKeyPathArg)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	// fallback: try `car create` (go-car)
-	if path, e := exec.LookPath("car"); e == nil && path != "" {
-		cmd := exec.Command("car", "create", "-o", tmpPath, srcDir)
-		if debug {
-			log.Printf("Running: %s", strings.Join(cmd.Args, " "))
+		proofs, err := auth.LoadProofs(auth.ProofPaths(proofPathArg)...)
+		if err != nil {
+			return nil, fmt.Errorf("extract proofs: %v", err)
 		}
-		out, err := cmd.CombinedOutput()
-		if err == nil {
-			if debug {
-				log.Printf("car create output: %s", string(out))
-			}
-			return tmpPath, nil
+
+		// Create a Guppy client with the issuer and proofs
+		guppyClient, err := client.NewClient(client.WithPrincipal(issuer))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create guppy client: %v", err)
 		}
-		if debug {
-			log.Printf("car create failed: %v out=%s", err, string(out))
+		if err := guppyClient.AddProofs(proofs...); err != nil {
+			return nil, fmt.Errorf("failed to add proofs to client: %v", err)
 		}
+		return guppyClient, nil
 	}
 
-	// try ipfs-car (npm) - alternative if npx not available
-	if path, e := exec.LookPath("ipfs-car"); e == nil && path != "" {
-		// ipfs-car usage: ipfs-car --pack <dir> --output <out>
-		cmd := exec.Command("ipfs-car", "--pack", srcDir, "--output", tmpPath)
+	if emailArg != "" {
+		// Interactive flow: the authenticated client carries the issuer and proofs
 		if debug {
-			log.Printf("Running: %s", strings.Join(cmd.Args, " "))
+			log.Printf("Using email authentication for upload (interactive)")
 		}
-		out, err := cmd.CombinedOutput()
+		guppyClient, err := auth.EmailAuth(emailArg)
 		if err != nil {
-			if debug {
-				log.Printf("ipfs-car failed: %v out=%s", err, string(out))
-			}
-			_ = os.Remove(tmpPath)
-			return "", fmt.Errorf("ipfs-car pack failed: %v: %s", err, string(out))
+			return nil, fmt.Errorf("email auth failed: %v", err)
 		}
-		if debug {
-			log.Printf("ipfs-car output: %s", string(out))
+		if guppyClient == nil {
+			return nil, fmt.Errorf("email auth failed for %s", emailArg)
 		}
-		return tmpPath, nil
+		return guppyClient, nil
 	}
 
-	_ = os.Remove(tmpPath)
-	return "", fmt.Errorf("no CAR packer found on PATH; install go-car (car) or ipfs-car (npm)")
-}
-
-// carRootCIDFromFile uses go-car to open the CAR and returns the first root CID (ipfs/go-cid)
-func carRootCIDFromFile(carPath string) (ipfscid.Cid, error) {
-	f, err := os.Open(carPath)
+	store, err := auth.DefaultAgentStore()
 	if err != nil {
-		return ipfscid.Cid{}, err
+		return nil, err
 	}
-	defer func() { _ = f.Close() }()
-
-	r, err := carv2.NewReader(f)
+	guppyClient, err := store.Client()
 	if err != nil {
-		return ipfscid.Cid{}, fmt.Errorf("open car: %v", err)
+		return nil, fmt.Errorf("load agent from %s: %v", store.Path(), err)
 	}
-	roots, err := r.Roots()
+	if len(guppyClient.Proofs()) == 0 {
+		return nil, fmt.Errorf("provide --private-key and --proof, or --email, or log in with `storachafs login`")
+	}
+	return guppyClient, nil
+}
+
+// hasStoredLogin reports whether a previous login left delegations to use
+func hasStoredLogin() bool {
+	store, err := auth.DefaultAgentStore()
 	if err != nil {
-		return ipfscid.Cid{}, fmt.Errorf("failed to get roots: %v", err)
+		return false
 	}
-	if len(roots) == 0 {
-		return ipfscid.Cid{}, fmt.Errorf("car has no roots")
+	data, ok, err := store.Load()
+	return err == nil && ok && len(data.Delegations) > 0
+}
+
+// reportUpload logs the content root of an upload along with the shards
+// and index it was stored as.
+func reportUpload(res upload.Result) {
+	log.Printf("✓ Directory uploaded with root CID: %s", res.Root)
+	for i, shard := range res.Shards {
+		log.Printf("  shard %d/%d: %s", i+1, len(res.Shards), shard)
 	}
-	root := roots[0]
-	return root, nil
+	log.Printf("  index: %s", res.Index)
 }
@@ -2,19 +2,170 @@
 package storachafs
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/space"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/worktree"
+	ipfscid "github.com/ipfs/go-cid"
 	"github.com/spf13/cobra"
 )
 
+var (
+	pullDryRun bool
+	pullPaths  []string
+	preferSide string
+)
+
 var pullCmd = &cobra.Command{
-	Use:   "pull",
+	Use:   "pull <cid-or-space> <localdir>",
 	Short: "Fetch new or updated files from Storacha",
+	Long: `Pull writes the UnixFS tree of a CID, or of the latest upload to a space
+DID, into a local directory. Only files whose CID differs from the one recorded
+by the last sync or pull are fetched, through the block cache, and the UnixFS
+mode and modification time are applied where the tree records them.
+
+A space's latest upload is only pulled into a directory that has no sync
+state yet, or that already holds it: the space may hold uploads of other trees,
+and merging one of those would delete the files it lacks. Pull a later version
+of a synced directory by its CID.
+
+Remote changes are merged with local ones against the last synced version:
+files changed on one side only take that side's version, and files changed on
+both keep the local version with the remote one saved next to it as
+<file>.conflict-<timestamp>, unless --prefer local or --prefer remote says
+which side wins. Every conflict and its resolution is listed.
+
+Resolving a space needs the same authentication as upload.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("pull command not yet implemented")
+		target, dir := args[0], args[1]
+		prefer, err := worktree.ParsePrefer(preferSide)
+		if err != nil {
+			log.Fatalf("--prefer: %v", err)
+		}
+
+		prev, err := worktree.LoadState(dir)
+		if err != nil {
+			log.Fatalf("Failed to read sync state: %v", err)
+		}
+		params, err := importParams(cmd, dir)
+		if err != nil {
+			log.Fatalf("Import settings error: %v", err)
+		}
+
+		root, space, err := resolvePullTarget(target)
+		if err != nil {
+			log.Fatalf("Failed to resolve %s: %v", target, err)
+		}
+		if space != "" {
+			fmt.Printf("Latest upload to %s is %s\n", space, root)
+		}
+		if prev.Synced() && root.Equals(prev.Root) && len(pullPaths) == 0 {
+			fmt.Printf("Already up to date: %s\n", root)
+			return
+		}
+		if err := prev.CheckRemote(root, space == ""); err != nil {
+			log.Fatalf("Not merging the latest upload to %s into %s: %v. A space holds every tree uploaded to it; if this is a version of %s, pull it by CID: storachafs pull %s %s", space, dir, err, dir, root, dir)
+		}
+
+		config, err := newClientConfig(true)
+		if err != nil {
+			log.Fatalf("Block cache error: %v", err)
+		}
+		client := fuse.NewStorachaClient(config)
+
+		if !pullDryRun {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				log.Fatalf("Failed to create %s: %v", dir, err)
+			}
+		}
+		next, changes, conflicts, err := worktree.Pull(client, root, dir, prev, worktree.PullOptions{
+			Params: params,
+			Hidden: includeHidden,
+			Paths:  pullPaths,
+			DryRun: pullDryRun,
+			Prefer: prefer,
+		})
+		if err != nil {
+			log.Fatalf("Failed to pull %s: %v (run pull again to continue)", root, err)
+		}
+
+		if pullDryRun {
+			fmt.Printf("Would pull %s into %s:\n", root, dir)
+			reportChanges(changes)
+			reportConflicts(conflicts)
+			return
+		}
+		if space != "" {
+			next.Space = space
+		}
+		next.SyncedAt = time.Now()
+		if err := next.Save(dir); err != nil {
+			log.Fatalf("Pulled %s but failed to record sync state: %v", root, err)
+		}
+		if err := params.Save(unixfs.ParamsPath(dir)); err != nil {
+			log.Printf("Failed to record import settings: %v", err)
+		}
+		fmt.Printf("✅ Pulled %s into %s\n", root, dir)
+		reportChanges(changes)
+		reportConflicts(conflicts)
 	},
 }
 
+// reportConflicts prints how each path changed on both sides was resolved
+func reportConflicts(conflicts []worktree.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Printf("%d conflicts:\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  ! %s (%s locally, %s remotely): %s\n", c.Path, c.Local, c.Remote, c.Resolution)
+	}
+}
+
+// resolvePullTarget returns the root to pull for a CID or space DID argument,
+// and the space it came from if it was one
+func resolvePullTarget(target string) (ipfscid.Cid, string, error) {
+	if !strings.HasPrefix(target, "did:") {
+		root, err := ipfscid.Decode(strings.TrimPrefix(target, "/ipfs/"))
+		if err == nil {
+			return root, "", nil
+		}
+		// not a CID, perhaps the name of a space
+		spaces, err := knownSpaces()
+		if err != nil {
+			return ipfscid.Undef, "", err
+		}
+		id, err := space.Resolve(spaces, target)
+		if err != nil {
+			return ipfscid.Undef, "", fmt.Errorf("%s is neither a CID nor a known space: %v", target, err)
+		}
+		target = id.String()
+	}
+	space, err := uploadSpace(email, privateKeyPath, proofPath, target, debug)
+	if err != nil {
+		return ipfscid.Undef, "", err
+	}
+	root, err := space.Latest(context.Background())
+	return root, target, err
+}
+
 func init() {
 	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().BoolVar(&pullDryRun, "dry-run", false, "list the changes a pull would make without making them")
+	pullCmd.Flags().StringVar(&preferSide, "prefer", "", "side that wins when a file changed both locally and remotely: local or remote (default: keep both)")
+	pullCmd.Flags().StringArrayVar(&pullPaths, "path", nil, "only pull this path, what is below it, or paths matching it as a glob (repeatable)")
+	pullCmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+	pullCmd.Flags().StringVar(&email, "email", "", "email for email-based authentication")
+	pullCmd.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
+	pullCmd.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file, or several separated by commas")
+	pullCmd.Flags().BoolVar(&includeHidden, "hidden", false, "include files and directories starting with \".\"")
+	addRetrievalFlags(pullCmd)
 }
@@ -0,0 +1,64 @@
+// cmd/storachafs/retrieval.go
+package storachafs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/cache"
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/spf13/cobra"
+)
+
+// retrieval settings shared by every command that reads content through fuse.StorachaClient
+var (
+	trustless      bool
+	gateways       []string
+	gatewayTimeout time.Duration
+	hedgeAfter     time.Duration
+	cacheDir       string
+	cacheSize      string
+)
+
+func addRetrievalFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&gateways, "gateway", nil, "IPFS gateway URL to retrieve from (repeatable, tried in order; default "+fuse.DefaultGateway+")")
+	cmd.Flags().DurationVar(&gatewayTimeout, "gateway-timeout", 30*time.Second, "timeout for a single gateway request before failing over")
+	cmd.Flags().DurationVar(&hedgeAfter, "hedge-after", 0, "send a duplicate request to the next gateway after this latency (0 disables)")
+	cmd.Flags().BoolVar(&trustless, "trustless", false, "fetch blocks as CARs and verify every block against its CID (for untrusted gateways)")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory of the persistent block cache (default $XDG_CACHE_HOME/storachafs/blocks)")
+	cmd.Flags().StringVar(&cacheSize, "cache-size", "10GiB", "maximum size of the block cache before least recently used blocks are evicted (0 disables the cache)")
+}
+
+// newClientConfig builds the client configuration from the retrieval flags,
+// opening the on-disk block cache unless withCache is false or --cache-size
+// is 0
+func newClientConfig(withCache bool) (*fuse.ClientConfig, error) {
+	config := &fuse.ClientConfig{
+		Debug:          debug,
+		Trustless:      trustless,
+		Gateways:       gateways,
+		GatewayTimeout: gatewayTimeout,
+		HedgeAfter:     hedgeAfter,
+	}
+	if !withCache {
+		return config, nil
+	}
+	maxSize, err := cache.ParseSize(cacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("--cache-size: %v", err)
+	}
+	if maxSize == 0 {
+		return config, nil
+	}
+
+	dir := cacheDir
+	if dir == "" {
+		if dir, err = cache.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Cache, err = cache.Open(dir, maxSize); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
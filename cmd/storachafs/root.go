@@ -14,6 +14,9 @@ var rootCmd = &cobra.Command{
 	Long: `StorachaFS is a Go-based FUSE filesystem that mounts Storacha
 spaces as POSIX-like directories, allowing seamless read/write access
 to files stored on the decentralized Storacha network.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		resolveSpaceFlag(cmd)
+	},
 }
 
 func Execute() {
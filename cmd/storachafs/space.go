@@ -0,0 +1,349 @@
+// cmd/storachafs/space.go
+package storachafs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/auth"
+	"github.com/ABD-AZE/StorachaFS/internal/space"
+	"github.com/spf13/cobra"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+	"github.com/storacha/guppy/pkg/client"
+)
+
+var spaceCmd = &cobra.Command{
+	Use:   "space",
+	Short: "List, create and choose Storacha spaces",
+	Long: `Spaces are found in the delegations held by the agent stored by login, and
+in --proof when it is given. Commands taking --space accept the name of one of
+them as well as a DID, and use the space chosen with "space use" when --space
+is left out.`,
+}
+
+var spaceLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the spaces the held delegations grant access to",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		spaces, err := knownSpaces()
+		if err != nil {
+			log.Fatalf("Failed to read delegations: %v", err)
+		}
+		if len(spaces) == 0 {
+			fmt.Println("No spaces (log in with storachafs login --email <email>, or create one with storachafs space create <name>)")
+			return
+		}
+		current := defaultSpace()
+		for _, s := range spaces {
+			mark := " "
+			if s.DID.String() == current {
+				mark = "*"
+			}
+			name := s.Name
+			if name == "" {
+				name = "(unnamed)"
+			}
+			fmt.Printf("%s %-20s %s\n", mark, name, s.DID)
+		}
+	},
+}
+
+var spaceCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a space and provision it with your account",
+	Long: `Create generates a new space, delegates full access to it to the stored agent
+and provisions it with the account the agent is logged in to (or --email), so
+uploads to it are billed to that account. The first space created becomes the
+default.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		c, err := agentClient(email, "", "", debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+
+		account := provisionAccount(c)
+
+		key, err := signer.Generate()
+		if err != nil {
+			log.Fatalf("Failed to create space: %v", err)
+		}
+		keyPath, err := store.SaveSpaceKey(key)
+		if err != nil {
+			log.Fatalf("Failed to save space key: %v", err)
+		}
+		s, err := space.Create(context.Background(), c, key, args[0], account)
+		if err != nil {
+			log.Printf("The space key is kept in %s", keyPath)
+			if s.DID != (did.DID{}) {
+				log.Fatalf("Failed to provision space %s: %v (retry with storachafs space provision %s)", s.DID, err, s.DID)
+			}
+			log.Fatalf("Failed to create space: %v", err)
+		}
+		fmt.Printf("✅ Created space %s: %s\n", s.Name, s.DID)
+		fmt.Printf("Provisioned with %s, delegation stored in %s\n", account, store.Path())
+		fmt.Printf("Space key saved to %s; keep a copy to recover the space\n", keyPath)
+		if defaultSpace() == "" {
+			setDefaultSpace(s.DID)
+		}
+	},
+}
+
+var spaceProvisionCmd = &cobra.Command{
+	Use:   "provision <name-or-did>",
+	Short: "Retry provisioning a space created here with your account",
+	Long: `Provision repeats the steps of "space create" that need the service for a
+space whose creation failed part way: it registers the space with the account
+the agent is logged in to (or --email) and stores a delegation of the space to
+the account. It needs the space key "space create" saved.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := resolveSpace(args[0])
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		key, err := store.SpaceKey(id)
+		if err != nil {
+			log.Fatalf("Failed to load space key: %v", err)
+		}
+		c, err := agentClient(email, "", "", debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		account := provisionAccount(c)
+
+		var name string
+		spaces, err := knownSpaces()
+		if err != nil {
+			log.Fatalf("Failed to read delegations: %v", err)
+		}
+		for _, s := range spaces {
+			if s.DID == id {
+				name = s.Name
+			}
+		}
+		if err := space.Provision(context.Background(), c, key, name, account); err != nil {
+			log.Fatalf("Failed to provision space %s: %v", id, err)
+		}
+		fmt.Printf("✅ Provisioned space %s with %s\n", id, account)
+	},
+}
+
+// provisionAccount returns the account --email names, or else the one
+// account the agent of c is logged in to, or exits
+func provisionAccount(c *client.Client) did.DID {
+	if email != "" {
+		account, err := auth.AccountDID(email)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		return account
+	}
+	accounts := auth.Accounts(c.Proofs())
+	switch len(accounts) {
+	case 0:
+		log.Fatalf("Not logged in to an account to provision the space with; run storachafs login --email <email> first")
+	case 1:
+		return accounts[0]
+	default:
+		log.Fatalf("Logged in to %d accounts; choose one with --email", len(accounts))
+	}
+	return did.DID{}
+}
+
+var spaceUseCmd = &cobra.Command{
+	Use:   "use <name-or-did>",
+	Short: "Set the space used when --space is not given",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id := resolveSpace(args[0])
+		spaces, err := knownSpaces()
+		if err != nil {
+			log.Fatalf("Failed to read delegations: %v", err)
+		}
+		if !hasSpace(spaces, id) {
+			log.Printf("Warning: no held delegation grants access to %s", id)
+		}
+		setDefaultSpace(id)
+	},
+}
+
+var spaceInfoCmd = &cobra.Command{
+	Use:   "info [name-or-did]",
+	Short: "Show a space's providers, usage and the abilities held in it",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := defaultSpace()
+		if len(args) == 1 {
+			target = args[0]
+		}
+		if target == "" {
+			log.Fatalf("No space given and no default space set (see storachafs space use)")
+		}
+		id := resolveSpace(target)
+
+		spaces, err := knownSpaces()
+		if err != nil {
+			log.Fatalf("Failed to read delegations: %v", err)
+		}
+		fmt.Printf("Space: %s\n", id)
+		for _, s := range spaces {
+			if s.DID != id {
+				continue
+			}
+			if s.Name != "" {
+				fmt.Printf("Name: %s\n", s.Name)
+			}
+			fmt.Printf("Abilities: %s\n", strings.Join(s.Abilities, ", "))
+			if s.Expires.IsZero() {
+				fmt.Println("Access: never expires")
+			} else {
+				fmt.Printf("Access: expires %s\n", s.Expires.Local().Format(time.DateTime))
+			}
+		}
+
+		c, err := agentClient(email, privateKeyPath, proofPath, debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		ctx := context.Background()
+		info, err := space.GetInfo(ctx, c, id)
+		if err != nil {
+			log.Fatalf("Failed to get space info: %v", err)
+		}
+		if len(info.Providers) == 0 {
+			fmt.Println("Providers: none (the space is not provisioned)")
+		} else {
+			fmt.Printf("Providers: %s\n", strings.Join(info.Providers, ", "))
+		}
+
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		used, err := space.Usage(ctx, c, id, month, now)
+		if err != nil {
+			fmt.Printf("Usage: unavailable (%v)\n", err)
+			return
+		}
+		fmt.Printf("Usage: %s\n", formatBytes(used))
+	},
+}
+
+// knownSpaces lists the spaces granted by the stored agent's delegations and
+// by --proof
+func knownSpaces() ([]space.Space, error) {
+	var proofs []delegation.Delegation
+	store, err := auth.DefaultAgentStore()
+	if err != nil {
+		return nil, err
+	}
+	data, ok, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		proofs = append(proofs, data.Delegations...)
+	}
+	if proofPath != "" {
+		loaded, err := auth.LoadProofs(auth.ProofPaths(proofPath)...)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, loaded...)
+	}
+	return space.List(proofs), nil
+}
+
+func hasSpace(spaces []space.Space, id did.DID) bool {
+	for _, s := range spaces {
+		if s.DID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSpace returns the DID of a space given by name or DID, or exits
+func resolveSpace(s string) did.DID {
+	var spaces []space.Space
+	if !strings.HasPrefix(s, "did:") {
+		var err error
+		if spaces, err = knownSpaces(); err != nil {
+			log.Fatalf("Failed to read delegations: %v", err)
+		}
+	}
+	id, err := space.Resolve(spaces, s)
+	if err != nil {
+		log.Fatalf("Unknown space %s: %v", s, err)
+	}
+	return id
+}
+
+// resolveSpaceFlag turns the --space of commands that have one into a DID:
+// a name is looked up among the known spaces, and a missing --space takes
+// the default space
+func resolveSpaceFlag(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("space") == nil {
+		return
+	}
+	if spaceDID == "" {
+		if spaceDID = defaultSpace(); spaceDID != "" {
+			log.Printf("Using default space %s", spaceDID)
+		}
+		return
+	}
+	spaceDID = resolveSpace(spaceDID).String()
+}
+
+// defaultSpace returns the DID chosen with "space use", if any
+func defaultSpace() string {
+	path, err := auth.DefaultConfigPath()
+	if err != nil {
+		return ""
+	}
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		log.Printf("Ignoring config: %v", err)
+		return ""
+	}
+	return config.Space
+}
+
+func setDefaultSpace(id did.DID) {
+	path, err := auth.DefaultConfigPath()
+	if err != nil {
+		log.Fatalf("Failed to save default space: %v", err)
+	}
+	config, err := auth.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to save default space: %v", err)
+	}
+	config.Space = id.String()
+	if err := config.Save(path); err != nil {
+		log.Fatalf("Failed to save default space: %v", err)
+	}
+	fmt.Printf("Default space is now %s\n", id)
+}
+
+func init() {
+	rootCmd.AddCommand(spaceCmd)
+	spaceCmd.AddCommand(spaceLsCmd, spaceCreateCmd, spaceProvisionCmd, spaceUseCmd, spaceInfoCmd)
+	spaceCmd.PersistentFlags().StringVar(&proofPath, "proof", "", "proof/delegation files to find spaces in, separated by commas")
+	spaceCreateCmd.Flags().StringVar(&email, "email", "", "account to provision the space with (default: the logged in account)")
+	spaceCreateCmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+	spaceProvisionCmd.Flags().StringVar(&email, "email", "", "account to provision the space with (default: the logged in account)")
+	spaceProvisionCmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+	spaceInfoCmd.Flags().StringVar(&email, "email", "", "email for email-based authentication")
+	spaceInfoCmd.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
+	spaceInfoCmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+}
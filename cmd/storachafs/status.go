@@ -3,18 +3,160 @@ package storachafs
 
 import (
 	"fmt"
+	"log"
+	"sort"
 
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/worktree"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusPorcelain bool
+	statusRemote    string
+)
+
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [localdir]",
 	Short: "Show local vs remote changes",
+	Long: `Status compares a synced directory with the state recorded by its last sync
+or pull. Local files are only hashed again when their size or modification time
+changed. Remote changes are listed when --remote names a CID or space DID, or
+when credentials are given for the space the directory was synced to.
+
+With --porcelain every changed path is printed as "XY path", where X is the
+local and Y the remote change (A added, M modified, D deleted, or a space when
+unchanged on that side) and directories end in "/".`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("status command not yet implemented")
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		st, err := worktree.LoadState(dir)
+		if err != nil {
+			log.Fatalf("Failed to read sync state: %v", err)
+		}
+		params, err := importParams(cmd, dir)
+		if err != nil {
+			log.Fatalf("Import settings error: %v", err)
+		}
+		local, err := worktree.LocalChanges(dir, st, unixfs.DirOptions{Params: params, Hidden: st.Hidden})
+		if err != nil {
+			log.Fatalf("Failed to scan %s: %v", dir, err)
+		}
+		if st.Synced() {
+			// keep the hashes of modified files so the next run need not read them
+			if err := st.Save(dir); err != nil {
+				log.Printf("Failed to record sync state: %v", err)
+			}
+		}
+
+		target := statusRemote
+		if target == "" && st.Space != "" && (privateKeyPath != "" || email != "") {
+			target = st.Space
+		}
+		var remote []worktree.Change
+		checked := target != "" && st.Synced()
+		if checked {
+			root, space, err := resolvePullTarget(target)
+			if err != nil {
+				log.Fatalf("Failed to resolve %s: %v", target, err)
+			}
+			if err := st.CheckRemote(root, space == ""); err != nil {
+				// a newer upload to the space may be of another tree
+				log.Printf("Not comparing with the latest upload to %s: %v; give its CID with --remote if it is", space, err)
+				checked = false
+			} else {
+				config, err := newClientConfig(true)
+				if err != nil {
+					log.Fatalf("Block cache error: %v", err)
+				}
+				remote, err = worktree.RemoteChanges(fuse.NewStorachaClient(config), root, st)
+				if err != nil {
+					log.Fatalf("Failed to list %s: %v", root, err)
+				}
+			}
+		}
+
+		if statusPorcelain {
+			printPorcelain(local, remote)
+			return
+		}
+		switch {
+		case !st.Synced():
+			fmt.Printf("%s has not been synced yet\n", dir)
+		case st.Space != "":
+			fmt.Printf("Last synced with %s as %s\n", st.Space, st.Root)
+		default:
+			fmt.Printf("Last pulled from %s\n", st.Root)
+		}
+		printChanges("Changes not synced yet", local)
+		if checked {
+			printChanges("Remote changes not pulled yet", remote)
+		} else if st.Synced() {
+			fmt.Println("Remote changes not checked (give --remote or credentials for the space)")
+		}
+		if len(local) == 0 && len(remote) == 0 {
+			fmt.Println("Nothing to sync, working tree clean")
+		}
 	},
 }
 
+// printChanges lists changes under a heading, if there are any
+func printChanges(heading string, changes []worktree.Change) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", heading)
+	for _, c := range changes {
+		p := c.Path
+		if c.Dir {
+			p += "/"
+		}
+		fmt.Printf("  %-9s %s\n", c.Kind.String()+":", p)
+	}
+}
+
+// printPorcelain prints one "XY path" line per path changed on either side
+func printPorcelain(local, remote []worktree.Change) {
+	type key struct {
+		path string
+		dir  bool
+	}
+	codes := make(map[key]*[2]byte)
+	var keys []key
+	mark := func(changes []worktree.Change, side int) {
+		for _, c := range changes {
+			k := key{c.Path, c.Dir}
+			if codes[k] == nil {
+				codes[k] = &[2]byte{' ', ' '}
+				keys = append(keys, k)
+			}
+			codes[k][side] = byte(c.Kind)
+		}
+	}
+	mark(local, 0)
+	mark(remote, 1)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].path < keys[j].path })
+	for _, k := range keys {
+		p := k.path
+		if k.dir {
+			p += "/"
+		}
+		fmt.Printf("%s %s\n", codes[k][:], p)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "print changes in a stable format for scripts")
+	statusCmd.Flags().StringVar(&statusRemote, "remote", "", "CID or space DID to compare the last sync with (default: the synced space, if credentials are given)")
+	statusCmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+	statusCmd.Flags().StringVar(&email, "email", "", "email for email-based authentication")
+	statusCmd.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
+	statusCmd.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file, or several separated by commas")
+	addRetrievalFlags(statusCmd)
 }
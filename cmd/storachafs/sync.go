@@ -2,19 +2,191 @@
 package storachafs
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/upload"
+	"github.com/ABD-AZE/StorachaFS/internal/worktree"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
 	"github.com/spf13/cobra"
 )
 
+var syncRemote string
+
 var syncCmd = &cobra.Command{
-	Use:   "sync",
+	Use:   "sync <localdir>",
 	Short: "Sync local files to Storacha",
+	Long: `Sync uploads the changes made to a local directory since its last sync to
+--space and registers the new root. Files whose size and modification time are
+unchanged are not read again, and only the blocks of new or modified files and
+of the directories above them are stored. The synced root and the CID of every
+path are recorded in <localdir>/.storacha/sync.json.
+
+With --remote, a version of the directory uploaded elsewhere is merged into it
+first, as pull does, so the new root carries both sides' changes; --prefer
+decides conflicting files the same way. The latest upload to the space is never
+merged on its own, since it may be of another tree; sync only reports it.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("sync command not yet implemented")
+		source := args[0]
+		if spaceDID == "" {
+			log.Fatalf("Space DID (--space) is required when syncing. Please provide a valid space DID.")
+		}
+		if info, err := os.Stat(source); err != nil || !info.IsDir() {
+			log.Fatalf("Cannot sync %s: not a directory", source)
+		}
+
+		prefer, err := worktree.ParsePrefer(preferSide)
+		if err != nil {
+			log.Fatalf("--prefer: %v", err)
+		}
+		params, err := importParams(cmd, source)
+		if err != nil {
+			log.Fatalf("Import settings error: %v", err)
+		}
+		opts := unixfs.DirOptions{Params: params, Hidden: includeHidden}
+
+		prev, err := worktree.LoadState(source)
+		if err != nil {
+			log.Fatalf("Failed to read sync state: %v", err)
+		}
+		if prev.Synced() && prev.Space != spaceDID {
+			// blocks synced to another space, or pulled from a bare CID,
+			// cannot be assumed present in this one
+			if prev.Space == "" {
+				log.Printf("%s was pulled from %s, which may not be in %s; uploading it in full", source, prev.Root, spaceDID)
+			} else {
+				log.Printf("%s was last synced to %s; uploading it to %s in full", source, prev.Space, spaceDID)
+			}
+			prev = &worktree.State{Entries: prev.Entries}
+		}
+
+		space, err := uploadSpace(email, privateKeyPath, proofPath, spaceDID, debug)
+		if err != nil {
+			log.Fatalf("Authentication error: %v", err)
+		}
+		prev = mergeRemote(space, source, prev, params, prefer)
+
+		// find out what changed first, so an unchanged tree is not uploaded
+		next, changes, err := worktree.Push(source, prev, opts, func(blocks.Block) error { return nil })
+		if err != nil {
+			log.Fatalf("Failed to scan %s: %v", source, err)
+		}
+		if prev.Synced() && next.Root.Equals(prev.Root) {
+			next.Space, next.SyncedAt = spaceDID, time.Now()
+			if err := next.Save(source); err != nil {
+				log.Printf("Failed to record sync state: %v", err)
+			}
+			fmt.Printf("Already up to date: %s\n", prev.Root)
+			return
+		}
+
+		log.Printf("Uploading %d changed paths of %s", len(changes), source)
+		res, err := space.UploadChanges(context.Background(), source, opts, func(sink unixfs.BlockSink) (ipfscid.Cid, error) {
+			next, changes, err = worktree.Push(source, prev, opts, sink)
+			if err != nil {
+				return ipfscid.Undef, err
+			}
+			return next.Root, nil
+		})
+		if err != nil {
+			log.Fatalf("Failed to sync %s: %v (run sync again to retry)", source, err)
+		}
+
+		next.Space, next.SyncedAt = spaceDID, time.Now()
+		if err := next.Save(source); err != nil {
+			log.Fatalf("Uploaded %s but failed to record sync state: %v", res.Root, err)
+		}
+		if err := params.Save(unixfs.ParamsPath(source)); err != nil {
+			log.Printf("Failed to record import settings: %v", err)
+		}
+		reportUpload(res)
+		reportChanges(changes)
 	},
 }
 
+// mergeRemote merges the version of source named by --remote into it and
+// returns the merged state to sync from. Without --remote nothing is merged:
+// newer uploads to the space may be of other trees, and merging one of those
+// would delete the synced files it lacks.
+func mergeRemote(space *upload.Space, source string, prev *worktree.State, params unixfs.Params, prefer worktree.Prefer) *worktree.State {
+	if syncRemote == "" {
+		if !prev.Synced() {
+			return prev
+		}
+		latest, err := space.Latest(context.Background())
+		if errors.Is(err, upload.ErrNoUploads) {
+			return prev
+		}
+		if err != nil {
+			log.Printf("Failed to check %s for newer uploads: %v", spaceDID, err)
+			return prev
+		}
+		if !latest.Equals(prev.Root) {
+			log.Printf("%s has uploads since %s was last synced, the latest being %s. They are not merged, since a space also holds uploads of other trees; if it is a version of this directory, merge it with --remote %s", spaceDID, source, latest, latest)
+		}
+		return prev
+	}
+
+	root, err := ipfscid.Decode(strings.TrimPrefix(syncRemote, "/ipfs/"))
+	if err != nil {
+		log.Fatalf("--remote must be the CID of a version of %s: %v", source, err)
+	}
+	if root.Equals(prev.Root) {
+		return prev
+	}
+
+	log.Printf("Merging %s into %s", root, source)
+	config, err := newClientConfig(true)
+	if err != nil {
+		log.Fatalf("Block cache error: %v", err)
+	}
+	merged, changes, conflicts, err := worktree.Pull(fuse.NewStorachaClient(config), root, source, prev, worktree.PullOptions{
+		Params: params,
+		Hidden: includeHidden,
+		Prefer: prefer,
+	})
+	if err != nil {
+		log.Fatalf("Failed to merge remote changes: %v (run sync again to retry)", err)
+	}
+	// the directory now holds the merge, record it before uploading
+	merged.Space, merged.SyncedAt = spaceDID, time.Now()
+	if err := merged.Save(source); err != nil {
+		log.Fatalf("Merged %s but failed to record sync state: %v", root, err)
+	}
+	fmt.Printf("Merged remote changes from %s:\n", root)
+	reportChanges(changes)
+	reportConflicts(conflicts)
+	return merged
+}
+
+// reportChanges prints one line per changed path followed by a count of
+// each kind of change
+func reportChanges(changes []worktree.Change) {
+	counts := make(map[worktree.ChangeKind]int)
+	for _, c := range changes {
+		p := c.Path
+		if c.Dir {
+			p += "/"
+		}
+		fmt.Printf("  %c %s\n", c.Kind, p)
+		counts[c.Kind]++
+	}
+	fmt.Printf("%d added, %d modified, %d deleted\n", counts[worktree.Added], counts[worktree.Modified], counts[worktree.Deleted])
+}
+
 func init() {
 	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVar(&syncRemote, "remote", "", "CID of a version of the directory, uploaded elsewhere, to merge before uploading")
+	syncCmd.Flags().StringVar(&preferSide, "prefer", "", "side that wins when a file changed both locally and remotely: local or remote (default: keep both)")
+	addUploadFlags(syncCmd)
+	addRetrievalFlags(syncCmd)
 }
@@ -0,0 +1,77 @@
+// cmd/storachafs/upload.go
+package storachafs
+
+import (
+	"context"
+	"log"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/ABD-AZE/StorachaFS/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var resumeUpload bool
+
+var uploadCmd = &cobra.Command{
+	Use:   "upload <localdir>",
+	Short: "Upload a local directory to a Storacha space",
+	Long: `Upload packs a local directory into CAR shards, stores them in --space and
+registers the UnixFS root. Each shard is stored as soon as it is full and its
+CID journaled in the user cache directory, so an upload interrupted by a crash
+or network failure can be continued with --resume without sending the stored
+shards again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		source := args[0]
+		if spaceDID == "" {
+			log.Fatalf("Space DID (--space) is required when uploading content. Please provide a valid space DID.")
+		}
+
+		var res upload.Result
+		if resumeUpload {
+			if !upload.HasJournal(source) {
+				log.Fatalf("No interrupted upload of %s to resume", source)
+			}
+			space, err := uploadSpace(email, privateKeyPath, proofPath, spaceDID, debug)
+			if err != nil {
+				log.Fatalf("Authentication error: %v", err)
+			}
+			res, err = space.Resume(context.Background(), source)
+			if err != nil {
+				log.Fatalf("Failed to resume upload: %v (run with --resume again to retry)", err)
+			}
+		} else {
+			params, err := importParams(cmd, source)
+			if err != nil {
+				log.Fatalf("Import settings error: %v", err)
+			}
+			log.Printf("Packing and uploading directory: %s", source)
+			res, err = uploadDirectoryWithAuth(source, email, privateKeyPath, proofPath, spaceDID, params, debug)
+			if err != nil {
+				log.Fatalf("Failed to upload directory: %v (run `storachafs upload --resume %s` to continue)", err, source)
+			}
+			if err := params.Save(unixfs.ParamsPath(source)); err != nil {
+				log.Printf("Failed to record import settings: %v", err)
+			}
+		}
+		reportUpload(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uploadCmd)
+	uploadCmd.Flags().BoolVar(&resumeUpload, "resume", false, "continue the interrupted upload of <localdir> from its journal")
+	addUploadFlags(uploadCmd)
+}
+
+// addUploadFlags registers the authentication and import flags of commands
+// that upload a local directory
+func addUploadFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&debug, "debug", false, "enable debug logging")
+	cmd.Flags().StringVar(&email, "email", "", "email for email-based authentication")
+	cmd.Flags().StringVar(&privateKeyPath, "private-key", "", "path to private key file")
+	cmd.Flags().StringVar(&proofPath, "proof", "", "path to proof/delegation file, or several separated by commas")
+	cmd.Flags().StringVar(&spaceDID, "space", "", "space to upload to, by DID or name (default: the space chosen with space use)")
+	cmd.Flags().BoolVar(&includeHidden, "hidden", false, "include files and directories starting with \".\"")
+	addImportFlags(cmd)
+}
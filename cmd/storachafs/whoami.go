@@ -0,0 +1,73 @@
+// cmd/storachafs/whoami.go
+package storachafs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/auth"
+	"github.com/spf13/cobra"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the stored agent, its accounts and proofs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := auth.DefaultAgentStore()
+		if err != nil {
+			log.Fatalf("Failed to open agent store: %v", err)
+		}
+		data, ok, err := store.Load()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", store.Path(), err)
+		}
+		if !ok {
+			fmt.Println("Not logged in (run storachafs login --email <email>)")
+			return
+		}
+
+		fmt.Printf("Agent: %s\n", data.Principal.DID())
+		accounts := auth.Accounts(data.Delegations)
+		if len(accounts) == 0 {
+			fmt.Println("Accounts: none")
+		}
+		for _, a := range accounts {
+			fmt.Printf("Account: %s\n", a)
+		}
+		fmt.Printf("Proofs (%d):\n", len(data.Delegations))
+		for _, p := range data.Delegations {
+			printProof(p)
+		}
+	},
+}
+
+// printProof prints who issued a delegation to whom, what it grants and
+// until when
+func printProof(p delegation.Delegation) {
+	fmt.Printf("  %s\n", p.Link())
+	fmt.Printf("    %s -> %s, %s\n", p.Issuer().DID(), p.Audience().DID(), formatExpiry(p))
+	for _, c := range p.Capabilities() {
+		fmt.Printf("    can %s on %s\n", c.Can(), c.With())
+	}
+}
+
+// formatExpiry describes when a delegation expires
+func formatExpiry(p delegation.Delegation) string {
+	exp := p.Expiration()
+	if exp == nil {
+		return "never expires"
+	}
+	t := time.Unix(int64(*exp), 0).Local().Format(time.DateTime)
+	if ucan.IsExpired(p) {
+		return "expired " + t
+	}
+	return "expires " + t
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
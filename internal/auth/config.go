@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+)
+
+// Config holds the user's preferences, kept next to the agent store
+type Config struct {
+	// Space is the DID of the space commands use when --space is not given
+	Space string `json:"space,omitempty"`
+}
+
+// DefaultConfigPath returns the per-user config file, e.g. ~/.config/storachafs/config.json
+func DefaultConfigPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// LoadConfig reads the config at path; a missing file is an empty config
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save replaces the config at path atomically
+func (c Config) Save(path string) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, raw, 0600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
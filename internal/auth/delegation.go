@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	"github.com/ABD-AZE/StorachaFS/internal/service"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/guppy/pkg/client"
+)
+
+// Delegate issues audience the abilities on resource, with the proofs
+// through which issuer holds them. A zero expiration never expires.
+func Delegate(issuer principal.Signer, proofs []delegation.Delegation, audience did.DID, resource string, abilities []string, expiration time.Time) (delegation.Delegation, error) {
+	if len(abilities) == 0 {
+		return nil, fmt.Errorf("no abilities to delegate")
+	}
+	caps := make([]ucan.Capability[ucan.NoCaveats], 0, len(abilities))
+	for _, can := range abilities {
+		caps = append(caps, ucan.NewCapability(can, resource, ucan.NoCaveats{}))
+	}
+
+	// the issuer of a resource, like a space's own key, needs no proof
+	var prfs []delegation.Proof
+	if resource != issuer.DID().String() {
+		prfs = proofsFor(proofs, resource)
+		if len(prfs) == 0 {
+			return nil, fmt.Errorf("no held delegation grants access to %s", resource)
+		}
+	}
+	opts := []delegation.Option{delegation.WithProof(prfs...)}
+	if expiration.IsZero() {
+		opts = append(opts, delegation.WithNoExpiration())
+	} else {
+		opts = append(opts, delegation.WithExpiration(int(expiration.Unix())))
+	}
+	return delegation.Delegate(issuer, audience, caps, opts...)
+}
+
+// proofsFor picks the unexpired proofs that may grant abilities on
+// resource: those naming it, an account's ucan:* delegations and the
+// service attestations that vouch for them
+func proofsFor(proofs []delegation.Delegation, resource string) []delegation.Proof {
+	var out []delegation.Proof
+	for _, p := range unexpired(proofs) {
+		for _, c := range p.Capabilities() {
+			if c.With() == resource || c.With() == "ucan:*" || c.Can() == "ucan/attest" {
+				out = append(out, delegation.FromDelegation(p))
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Revoke asks the service to revoke d, which the agent of c issued
+func Revoke(ctx context.Context, c *client.Client, d delegation.Delegation) error {
+	link, ok := d.Link().(cidlink.Link)
+	if !ok {
+		return fmt.Errorf("unexpected link type %T", d.Link())
+	}
+	_, err := service.Invoke(ctx, c, "ucan/revoke", c.DID().String(), service.Caveats{"ucan": link}, d)
+	return err
+}
+
+// issuedDir keeps an archive of each delegation the agent issued, so it can
+// be listed and revoked later
+func (s *AgentStore) issuedDir() string {
+	return filepath.Join(filepath.Dir(s.path), "issued")
+}
+
+// SaveIssued keeps d among the issued delegations and returns the path of
+// its archive
+func (s *AgentStore) SaveIssued(d delegation.Delegation) (string, error) {
+	dir := s.issuedDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(d.Archive())
+	if err != nil {
+		return "", fmt.Errorf("archive delegation: %w", err)
+	}
+	path := filepath.Join(dir, d.Link().String()+".car")
+	if err := atomicfile.WriteFile(path, raw, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Issued returns the delegations kept by SaveIssued
+func (s *AgentStore) Issued() ([]delegation.Delegation, error) {
+	entries, err := os.ReadDir(s.issuedDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var issued []delegation.Delegation
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".car") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.issuedDir(), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		d, err := delegation.Extract(raw)
+		if err != nil {
+			return nil, fmt.Errorf("read issued delegation %s: %w", e.Name(), err)
+		}
+		issued = append(issued, d)
+	}
+	return issued, nil
+}
+
+// RemoveIssued forgets the issued delegation with the given CID
+func (s *AgentStore) RemoveIssued(link string) error {
+	err := os.Remove(filepath.Join(s.issuedDir(), link+".car"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
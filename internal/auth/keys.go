@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+)
+
+// LoadPrivateKey reads the ed25519 key of an agent from path, in any of the
+// formats ParsePrivateKey accepts
+func LoadPrivateKey(path string) (principal.Signer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("private key path is empty")
+	}
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file '%s': %w", path, err)
+	}
+	key, err := ParsePrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key file '%s': %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey decodes an ed25519 private key, detecting its format:
+//   - PEM holding a PKCS#8 key, as written by openssl genpkey -algorithm ed25519
+//   - a JWK with kty OKP and crv Ed25519
+//   - the multibase "Mg..." string Storacha tools print for a new key
+//   - base64 of a 32 byte seed, a 64 byte private key, or the multicodec
+//     tagged key the multibase string also holds
+func ParsePrivateKey(b []byte) (principal.Signer, error) {
+	text := strings.TrimSpace(string(b))
+	if text == "" {
+		return nil, fmt.Errorf("private key is empty")
+	}
+
+	switch {
+	case strings.HasPrefix(text, "-----BEGIN"):
+		return parsePEMKey(text)
+	case strings.HasPrefix(text, "{"):
+		return parseJWK(text)
+	}
+	if s, err := signer.Parse(text); err == nil {
+		return fromPrivateKey(s.Raw())
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		raw, err := enc.DecodeString(text)
+		if err != nil {
+			continue
+		}
+		return fromBytes(raw)
+	}
+	return nil, fmt.Errorf("unrecognized key format: expected a multibase Mg... key, base64, PEM (PKCS#8) or JWK")
+}
+
+// fromBytes interprets decoded key bytes by their length
+func fromBytes(raw []byte) (principal.Signer, error) {
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return signer.FromRaw(ed25519.NewKeyFromSeed(raw))
+	case ed25519.PrivateKeySize:
+		return fromPrivateKey(raw)
+	}
+	s, err := signer.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%d byte key is neither an ed25519 seed, private key nor tagged key: %w", len(raw), err)
+	}
+	return fromPrivateKey(s.Raw())
+}
+
+// fromPrivateKey rebuilds a 64 byte ed25519 private key from its seed half
+// and checks that its public half matches, so a corrupted key is refused
+// instead of yielding a DID it cannot sign for
+func fromPrivateKey(raw []byte) (principal.Signer, error) {
+	key := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize])
+	if !key.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(raw[ed25519.SeedSize:])) {
+		return nil, fmt.Errorf("public half of the ed25519 key does not match its seed")
+	}
+	return signer.FromRaw(key)
+}
+
+func parsePEMKey(text string) (principal.Signer, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM block")
+	}
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("unsupported PEM block %q: expected a PKCS#8 \"PRIVATE KEY\"", block.Type)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#8 key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM key is a %T, not ed25519", key)
+	}
+	return signer.FromRaw(edKey)
+}
+
+// jwk is the part of a JSON Web Key an ed25519 private key needs
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	D   string `json:"d"`
+	X   string `json:"x"`
+}
+
+func parseJWK(text string) (principal.Signer, error) {
+	var k jwk
+	if err := json.Unmarshal([]byte(text), &k); err != nil {
+		return nil, fmt.Errorf("parse JWK: %w", err)
+	}
+	if k.Kty != "OKP" || k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported JWK kty %q crv %q: expected OKP Ed25519", k.Kty, k.Crv)
+	}
+	if k.D == "" {
+		return nil, fmt.Errorf("JWK holds no private key (\"d\")")
+	}
+	seed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.D, "="))
+	if err != nil {
+		return nil, fmt.Errorf("decode JWK \"d\": %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("JWK \"d\" is %d bytes, expected %d", len(seed), ed25519.SeedSize)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	if k.X != "" {
+		pub, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.X, "="))
+		if err != nil || !ed25519.PublicKey(pub).Equal(key.Public()) {
+			return nil, fmt.Errorf("JWK \"x\" does not match its private key")
+		}
+	}
+	return signer.FromRaw(key)
+}
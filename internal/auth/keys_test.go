@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i * 7)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	want, err := signer.FromRaw(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := want.Encode()
+	multibase, err := signer.Format(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+
+	// the same keys with a public half that belongs to another seed
+	other := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	badKey := append(append([]byte(nil), key[:ed25519.SeedSize]...), other[ed25519.SeedSize:]...)
+	badSigner, err := signer.FromRaw(badKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badMultibase, err := signer.Format(badSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := func(x []byte) string {
+		return fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","d":%q,"x":%q}`, b64(seed), b64(x))
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "multibase", text: multibase},
+		{name: "multibase with newline", text: multibase + "\n"},
+		{name: "padded base64 seed", text: base64.StdEncoding.EncodeToString(seed)},
+		{name: "unpadded base64 seed", text: base64.RawStdEncoding.EncodeToString(seed)},
+		{name: "URL base64 seed", text: base64.URLEncoding.EncodeToString(seed)},
+		{name: "unpadded URL base64 seed", text: base64.RawURLEncoding.EncodeToString(seed)},
+		{name: "padded base64 private key", text: base64.StdEncoding.EncodeToString(key)},
+		{name: "unpadded base64 private key", text: base64.RawStdEncoding.EncodeToString(key)},
+		{name: "URL base64 private key", text: base64.URLEncoding.EncodeToString(key)},
+		{name: "unpadded URL base64 private key", text: base64.RawURLEncoding.EncodeToString(key)},
+		{name: "padded base64 tagged key", text: base64.StdEncoding.EncodeToString(tagged)},
+		{name: "unpadded base64 tagged key", text: base64.RawStdEncoding.EncodeToString(tagged)},
+		{name: "URL base64 tagged key", text: base64.URLEncoding.EncodeToString(tagged)},
+		{name: "unpadded URL base64 tagged key", text: base64.RawURLEncoding.EncodeToString(tagged)},
+		{name: "PKCS#8 PEM", text: pemKey},
+		{name: "JWK", text: jwk(key.Public().(ed25519.PublicKey))},
+		{name: "JWK without x", text: fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","d":%q}`, b64(seed))},
+
+		{name: "JWK with mismatched x", text: jwk(other.Public().(ed25519.PublicKey)), wantErr: true},
+		{name: "JWK of another curve", text: fmt.Sprintf(`{"kty":"OKP","crv":"X25519","d":%q}`, b64(seed)), wantErr: true},
+		{name: "JWK without d", text: `{"kty":"OKP","crv":"Ed25519","x":"AAAA"}`, wantErr: true},
+		{name: "private key with mismatched public half", text: base64.StdEncoding.EncodeToString(badKey), wantErr: true},
+		{name: "tagged key with mismatched public half", text: base64.StdEncoding.EncodeToString(badSigner.Encode()), wantErr: true},
+		{name: "multibase with mismatched public half", text: badMultibase, wantErr: true},
+		{name: "base64 of the wrong length", text: base64.StdEncoding.EncodeToString(seed[:16]), wantErr: true},
+		{name: "PEM of another type", text: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: pkcs8})), wantErr: true},
+		{name: "empty", text: " \n", wantErr: true},
+		{name: "garbage", text: "not a key!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrivateKey([]byte(tt.text))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsed as %s, want an error", got.DID())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.DID() != want.DID() {
+				t.Errorf("DID = %s, want %s", got.DID(), want.DID())
+			}
+		})
+	}
+}
@@ -7,42 +7,48 @@ import (
 	"path/filepath"
 	"strings"
 
-	"encoding/base64"
-
-	"github.com/storacha/go-ucanto/core/delegation"
 	"github.com/storacha/go-ucanto/core/result"
 	"github.com/storacha/go-ucanto/did"
-	"github.com/storacha/go-ucanto/principal"
-	"github.com/storacha/go-ucanto/principal/ed25519/signer"
 	"github.com/storacha/guppy/pkg/client"
-	guppyDelegation "github.com/storacha/guppy/pkg/delegation"
 )
 
 var CachedClients = make(map[string]*client.Client)
 
+// EmailAuth returns a client authorized by the account of email. The agent
+// and its proofs are kept in the default AgentStore, so the confirmation
+// email is only sent again once the stored proofs have expired.
 func EmailAuth(email string) (*client.Client, error) {
-	if _, ok := CachedClients[email]; ok {
-		return CachedClients[email], nil
+	if c, ok := CachedClients[email]; ok {
+		return c, nil
 	}
-	CachedClients[email], _ = emailAuth(email)
-	return CachedClients[email], nil
+	store, err := DefaultAgentStore()
+	if err != nil {
+		return nil, err
+	}
+	c, err := emailAuth(email, store)
+	if err != nil {
+		return nil, err
+	}
+	CachedClients[email] = c
+	return c, nil
 }
 
-func emailAuth(email string) (*client.Client, error) {
+func emailAuth(email string, store *AgentStore) (*client.Client, error) {
 	ctx := context.Background()
 
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid email: %s", email)
-	}
-	emailUser, emailDomain := parts[0], parts[1]
-
-	account, err := did.Parse("did:mailto:" + emailDomain + ":" + emailUser)
+	account, err := AccountDID(email)
 	if err != nil {
 		return nil, err
 	}
 
-	c, _ := client.NewClient()
+	c, err := store.Client()
+	if err != nil {
+		return nil, fmt.Errorf("load agent from %s: %w", store.Path(), err)
+	}
+	if HasAccountProof(c.Proofs(), account) {
+		fmt.Printf("✓ Using stored login of %s (agent %s)\n", email, c.DID())
+		return c, nil
+	}
 
 	authOk, err := c.RequestAccess(ctx, account.String())
 	if err != nil {
@@ -56,6 +62,7 @@ func emailAuth(email string) (*client.Client, error) {
 		return nil, err
 	}
 
+	// saved to the store along with the agent key
 	if err := c.AddProofs(proofs...); err != nil {
 		return nil, fmt.Errorf("failed to add proofs: %w", err)
 	}
@@ -63,6 +70,16 @@ func emailAuth(email string) (*client.Client, error) {
 	return c, nil
 }
 
+// AccountDID returns the did:mailto account of email
+func AccountDID(email string) (did.DID, error) {
+	parts := strings.Split(email, "@")
+	if len(parts) != 2 {
+		return did.DID{}, fmt.Errorf("invalid email: %s", email)
+	}
+	emailUser, emailDomain := parts[0], parts[1]
+	return did.Parse("did:mailto:" + emailDomain + ":" + emailUser)
+}
+
 // AuthConfig holds the private key authentication configuration
 type AuthConfig struct {
 	PrivateKeyPath string
@@ -78,12 +95,12 @@ func PrivateKeyAuth(config *AuthConfig) (*client.Client, error) {
 		return cl, nil
 	}
 
-	issuer, err := loadPrivateKey(config.PrivateKeyPath)
+	issuer, err := LoadPrivateKey(config.PrivateKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %w", err)
 	}
 
-	proofs, err := loadProofs(config.ProofPath)
+	proofs, err := LoadProofs(ProofPaths(config.ProofPath)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load proofs: %w", err)
 	}
@@ -122,67 +139,6 @@ func PrivateKeyAuthSimple(privateKeyPath, proofPath, spaceDID string) (*client.C
 	return PrivateKeyAuth(config)
 }
 
-func loadPrivateKey(privateKeyPath string) (principal.Signer, error) {
-
-	if privateKeyPath == "" {
-		return nil, fmt.Errorf("private key path is empty")
-	}
-	if privateKeyPath[0] == '~' {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		privateKeyPath = filepath.Join(homeDir, privateKeyPath[1:])
-	}
-
-	keyData, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read private key file '%s': %w", privateKeyPath, err)
-	}
-
-	keyString := strings.TrimSpace(string(keyData))
-
-	// decoding base64 private key (getting issue here)
-	keybytes, err := base64.StdEncoding.DecodeString(keyString)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 private key: %w", err)
-	}
-
-	issuer, err := signer.FromRaw(keybytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
-	}
-
-	fmt.Printf("Successfully parsed private key\n")
-	return issuer, nil
-}
-
-func loadProofs(proofPath string) ([]delegation.Delegation, error) {
-	// Expand home directory if needed
-	if proofPath == "" {
-		return nil, fmt.Errorf("proof path is empty")
-	}
-	if proofPath[0] == '~' {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		proofPath = filepath.Join(homeDir, proofPath[1:])
-	}
-
-	prfbytes, err := os.ReadFile(proofPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read proof file '%s': %w", proofPath, err)
-	}
-
-	proof, err := guppyDelegation.ExtractProof(prfbytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse proof: %w", err)
-	}
-
-	return []delegation.Delegation{proof}, nil
-}
-
 // LoadAuthConfigFromFlags creates auth config from command line parameters
 func LoadAuthConfigFromFlags(privateKeyPath, proofPath, spaceDID string) *AuthConfig {
 	return &AuthConfig{
@@ -235,22 +191,20 @@ func ValidateAuthConfig(config *AuthConfig) error {
 		privateKeyPath = filepath.Join(homeDir, privateKeyPath[1:])
 	}
 
-	if proofPath[0] == '~' {
-		homeDir, err := os.UserHomeDir()
-		if err != nil || homeDir == "" {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
-		proofPath = filepath.Join(homeDir, proofPath[1:])
-	}
-
 	// Validate private key file
 	if _, err := os.Stat(privateKeyPath); os.IsNotExist(err) {
 		return fmt.Errorf("private key file does not exist: %s", privateKeyPath)
 	}
 
-	// Validate proof file
-	if _, err := os.Stat(proofPath); os.IsNotExist(err) {
-		return fmt.Errorf("proof file does not exist: %s", proofPath)
+	// Validate proof files
+	for _, p := range ProofPaths(proofPath) {
+		p, err := expandHome(p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			return fmt.Errorf("proof file does not exist: %s", p)
+		}
 	}
 
 	// Validate space DID format
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/storacha/go-ucanto/core/delegation"
+	guppyDelegation "github.com/storacha/guppy/pkg/delegation"
+)
+
+// ParseProof decodes a delegation in any form Storacha tools write one: a
+// CAR archive, a legacy CAR whose last block is the delegation, or the
+// base64 CID text printed by `delegation create --base64`
+func ParseProof(b []byte) (delegation.Delegation, error) {
+	if text := strings.TrimSpace(string(b)); text != "" && !strings.ContainsAny(text, "\x00\n") {
+		if d, err := delegation.Parse(text); err == nil {
+			return d, nil
+		}
+	}
+	return guppyDelegation.ExtractProof(b)
+}
+
+// LoadProofs reads one delegation from each of paths
+func LoadProofs(paths ...string) ([]delegation.Delegation, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("proof path is empty")
+	}
+	proofs := make([]delegation.Delegation, 0, len(paths))
+	for _, p := range paths {
+		path, err := expandHome(p)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proof file '%s': %w", path, err)
+		}
+		proof, err := ParseProof(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proof '%s': %w", path, err)
+		}
+		proofs = append(proofs, proof)
+	}
+	return proofs, nil
+}
+
+// ProofPaths splits a --proof value, which may list several files separated
+// by commas
+func ProofPaths(s string) []string {
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// expandHome replaces a leading "~" with the user's home directory
+func expandHome(path string) (string, error) {
+	if path == "" || path[0] != '~' {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+)
+
+// spaceKeyDir keeps the key of each space created by the agent, like the
+// recovery key other Storacha clients print, so a space whose provisioning
+// failed can still be provisioned and delegated later
+func (s *AgentStore) spaceKeyDir() string {
+	return filepath.Join(filepath.Dir(s.path), "spaces")
+}
+
+// SpaceKeyPath is the file SaveSpaceKey keeps the key of space in
+func (s *AgentStore) SpaceKeyPath(space did.DID) string {
+	return filepath.Join(s.spaceKeyDir(), space.String()+".key")
+}
+
+// SaveSpaceKey keeps the key of a new space, readable by the owner only, as
+// the multibase string ParsePrivateKey accepts
+func (s *AgentStore) SaveSpaceKey(key principal.Signer) (string, error) {
+	text, err := signer.Format(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.spaceKeyDir(), 0700); err != nil {
+		return "", err
+	}
+	path := s.SpaceKeyPath(key.DID())
+	if err := atomicfile.WriteFile(path, []byte(text+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("write space key: %w", err)
+	}
+	return path, nil
+}
+
+// SpaceKey returns the key SaveSpaceKey kept for space
+func (s *AgentStore) SpaceKey(space did.DID) (principal.Signer, error) {
+	path := s.SpaceKeyPath(space)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no key for %s in %s; only spaces created here can be provisioned", space, s.spaceKeyDir())
+	}
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if key.DID() != space {
+		return nil, fmt.Errorf("%s holds the key of %s, not %s", path, key.DID(), space)
+	}
+	return key, nil
+}
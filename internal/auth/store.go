@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/guppy/pkg/agentdata"
+	"github.com/storacha/guppy/pkg/client"
+)
+
+// AgentStore keeps the agent key and the delegations granted to it on disk,
+// readable by the owner only, so a login outlives the process
+type AgentStore struct {
+	path string
+}
+
+// ConfigDir returns the per-user storachafs directory, e.g. ~/.config/storachafs
+func ConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+	return filepath.Join(base, "storachafs"), nil
+}
+
+// DefaultAgentPath returns the per-user agent file, e.g. ~/.config/storachafs/agent.json
+func DefaultAgentPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agent.json"), nil
+}
+
+// OpenAgentStore returns the store at path; nothing is read until Load
+func OpenAgentStore(path string) *AgentStore {
+	return &AgentStore{path: path}
+}
+
+// DefaultAgentStore opens the store at DefaultAgentPath
+func DefaultAgentStore() (*AgentStore, error) {
+	path, err := DefaultAgentPath()
+	if err != nil {
+		return nil, err
+	}
+	return OpenAgentStore(path), nil
+}
+
+// Path is the file the store keeps the agent in
+func (s *AgentStore) Path() string {
+	return s.path
+}
+
+// Load reads the stored agent; ok is false if none was stored yet. Like ssh
+// keys, a file other users can read is refused.
+func (s *AgentStore) Load() (data agentdata.AgentData, ok bool, err error) {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return agentdata.AgentData{}, false, nil
+	}
+	if err != nil {
+		return agentdata.AgentData{}, false, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return agentdata.AgentData{}, false, fmt.Errorf("%s is accessible by other users (mode %v); run chmod 600 on it", s.path, info.Mode().Perm())
+	}
+
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		return agentdata.AgentData{}, false, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return agentdata.AgentData{}, false, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	return data, true, nil
+}
+
+// Save replaces the stored agent atomically
+func (s *AgentStore) Save(data agentdata.AgentData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.path, raw, 0600); err != nil {
+		return fmt.Errorf("write agent store: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the stored agent, its delegations and those it issued;
+// removed is false if no agent was stored
+func (s *AgentStore) Remove() (removed bool, err error) {
+	if err := os.RemoveAll(s.issuedDir()); err != nil {
+		return false, err
+	}
+	err = os.Remove(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Client returns a client acting as the stored agent, or as a new agent if
+// none is stored. Proofs added to it are saved to the store.
+func (s *AgentStore) Client() (*client.Client, error) {
+	data, ok, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	opts := []client.Option{client.WithSaveFn(s.Save)}
+	if ok {
+		data.Delegations = unexpired(data.Delegations)
+		opts = append(opts, client.WithData(data))
+	}
+	return client.NewClient(opts...)
+}
+
+// HasAccountProof reports whether proofs hold an unexpired delegation issued
+// by account, as a confirmed email login grants
+func HasAccountProof(proofs []delegation.Delegation, account did.DID) bool {
+	for _, p := range unexpired(proofs) {
+		if p.Issuer().DID() == account {
+			return true
+		}
+	}
+	return false
+}
+
+// Accounts returns the accounts that delegated to the agent in proofs, in
+// the order they first appear
+func Accounts(proofs []delegation.Delegation) []did.DID {
+	seen := make(map[did.DID]bool)
+	var accounts []did.DID
+	for _, p := range proofs {
+		issuer := p.Issuer().DID()
+		if !strings.HasPrefix(issuer.String(), "did:mailto:") || seen[issuer] {
+			continue
+		}
+		seen[issuer] = true
+		accounts = append(accounts, issuer)
+	}
+	return accounts
+}
+
+// unexpired drops the delegations that can no longer be used
+func unexpired(proofs []delegation.Delegation) []delegation.Delegation {
+	var out []delegation.Delegation
+	for _, p := range proofs {
+		if !ucan.IsExpired(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,250 @@
+// Package cache implements a persistent, content-addressed block cache on
+// disk. Storacha content is immutable, so a block fetched once can be served
+// from disk by every later open, mount or command on the same machine.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// evict back down to this fraction of the limit so eviction is not re-run on every write
+const lowWatermark = 0.9
+
+// BlockCache stores blocks as one file per CID under dir. Writes go through a
+// temp file and rename, so concurrent processes never observe partial blocks,
+// and eviction is serialised between processes with an flock on dir/.lock.
+type BlockCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	written int64 // bytes put since the last eviction pass
+}
+
+// DefaultDir returns the per-user cache directory, e.g. ~/.cache/storachafs/blocks
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(base, "storachafs", "blocks"), nil
+}
+
+// Open creates dir if needed and trims it to maxSize, which must be positive
+func Open(dir string, maxSize int64) (*BlockCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("cache size must be positive, got %d", maxSize)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+	c := &BlockCache{dir: dir, maxSize: maxSize}
+	if err := c.evict(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Dir returns the directory the cache lives in
+func (c *BlockCache) Dir() string {
+	return c.dir
+}
+
+// path maps a CID to its file; CIDv0 and CIDv1 of the same block share a
+// file, and the base32 name is safe on case-insensitive filesystems
+func (c *BlockCache) path(cid ipfscid.Cid) string {
+	key := ipfscid.NewCidV1(cid.Type(), cid.Hash()).String()
+	return filepath.Join(c.dir, key[len(key)-2:], key)
+}
+
+// Get returns a cached block and marks it as recently used
+func (c *BlockCache) Get(cid ipfscid.Cid) ([]byte, bool) {
+	p := c.path(cid)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(p, now, now) // mtime is the LRU clock
+	return data, true
+}
+
+// Peek returns a cached block without marking it as recently used
+func (c *BlockCache) Peek(cid ipfscid.Cid) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(cid))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Has reports whether a block is cached without touching it
+func (c *BlockCache) Has(cid ipfscid.Cid) bool {
+	_, err := os.Stat(c.path(cid))
+	return err == nil
+}
+
+// Put stores a block after checking that it hashes to cid, so a cache shared
+// with trustless mounts can never be poisoned by an unverified fetch
+func (c *BlockCache) Put(cid ipfscid.Cid, data []byte) error {
+	got, err := cid.Prefix().Sum(data)
+	if err != nil {
+		return fmt.Errorf("hash block %s: %w", cid, err)
+	}
+	if !got.Equals(cid) {
+		return fmt.Errorf("refusing to cache %s: content hashes to %s", cid, got)
+	}
+
+	p := c.path(cid)
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "block-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	c.written += int64(len(data))
+	due := c.written > c.maxSize-int64(float64(c.maxSize)*lowWatermark)
+	c.mu.Unlock()
+	if due {
+		return c.evict()
+	}
+	return nil
+}
+
+type cachedBlock struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+// evict deletes least recently used blocks until the cache is under the low
+// watermark. If another process holds the lock it is already evicting, so
+// this pass is skipped.
+func (c *BlockCache) evict() error {
+	c.mu.Lock()
+	c.written = 0
+	c.mu.Unlock()
+
+	lock, err := os.OpenFile(filepath.Join(c.dir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cache lock: %w", err)
+	}
+	defer func() { _ = lock.Close() }()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil
+		}
+		return fmt.Errorf("failed to lock cache: %w", err)
+	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	var blocks []cachedBlock
+	var total int64
+	err = filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed by a concurrent writer or evictor
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "tmp" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		blocks = append(blocks, cachedBlock{path: p, size: info.Size(), mtime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan cache: %w", err)
+	}
+	if total <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].mtime.Before(blocks[j].mtime) })
+	target := int64(float64(c.maxSize) * lowWatermark)
+	removed := 0
+	for _, b := range blocks {
+		if total <= target {
+			break
+		}
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Failed to evict cached block %s: %v", b.path, err)
+			continue
+		}
+		total -= b.size
+		removed++
+	}
+	log.Printf("Evicted %d cached blocks from %s", removed, c.dir)
+	return nil
+}
+
+// ParseSize parses a byte count with an optional binary suffix, e.g. "512M" or "10GiB"
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+	shift := 0
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+		if shift != 0 {
+			str = str[:len(str)-1]
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
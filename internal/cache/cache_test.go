@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+func rawCID(t *testing.T, data []byte) ipfscid.Cid {
+	t.Helper()
+	c, err := ipfscid.V1Builder{Codec: ipfscid.Raw, MhType: multihash.SHA2_256}.Sum(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPutRejectsMismatchedBlock(t *testing.T) {
+	c, err := Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := rawCID(t, []byte("genuine"))
+	if err := c.Put(cid, []byte("forged")); err == nil {
+		t.Fatal("Put cached bytes that do not hash to the CID")
+	}
+	if c.Has(cid) {
+		t.Fatal("mismatched block is cached")
+	}
+
+	if err := c.Put(cid, []byte("genuine")); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := c.Get(cid); !ok || string(data) != "genuine" {
+		t.Errorf("Get = %q, %v; want the genuine block", data, ok)
+	}
+}
+
+func TestPutEvictsOldestToLowWatermark(t *testing.T) {
+	const maxSize, blockSize = 1000, 100
+	c, err := Open(t.TempDir(), maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fill the cache exactly, each block used a second after the previous one
+	base := time.Now().Add(-time.Hour)
+	var cids []ipfscid.Cid
+	for i := 0; i < maxSize/blockSize; i++ {
+		cid := rawCID(t, bytes.Repeat([]byte{byte(i)}, blockSize))
+		if err := c.Put(cid, bytes.Repeat([]byte{byte(i)}, blockSize)); err != nil {
+			t.Fatal(err)
+		}
+		at := base.Add(time.Duration(i) * time.Second)
+		if err := os.Chtimes(c.path(cid), at, at); err != nil {
+			t.Fatal(err)
+		}
+		cids = append(cids, cid)
+	}
+	for i, cid := range cids {
+		if !c.Has(cid) {
+			t.Fatalf("block %d evicted before the cache was over its limit", i)
+		}
+	}
+
+	// two more blocks go over the limit and, being more than a tenth of it
+	// written since the last pass, trigger eviction: the oldest blocks are
+	// evicted until the cache is back under 90% of the limit
+	var extras []ipfscid.Cid
+	for i := 0; i < 2; i++ {
+		extra := bytes.Repeat([]byte{0xf0 + byte(i)}, blockSize)
+		if err := c.Put(rawCID(t, extra), extra); err != nil {
+			t.Fatal(err)
+		}
+		extras = append(extras, rawCID(t, extra))
+	}
+	const evicted = 3 // 1200 bytes down to 900
+	for i, cid := range cids {
+		if got, want := c.Has(cid), i >= evicted; got != want {
+			t.Errorf("block %d cached = %v, want %v", i, got, want)
+		}
+	}
+	for i, cid := range extras {
+		if !c.Has(cid) {
+			t.Errorf("new block %d evicted", i)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"4096", 4096, true},
+		{"64k", 64 << 10, true},
+		{"512M", 512 << 20, true},
+		{"10GiB", 10 << 30, true},
+		{" 2TB ", 2 << 40, true},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"-1", 0, false},
+		{"1.5G", 0, false},
+		{"10XB", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("ParseSize(%q) = %d, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
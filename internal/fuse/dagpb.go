@@ -0,0 +1,97 @@
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ipfs/boxo/ipld/unixfs"
+	unixfspb "github.com/ipfs/boxo/ipld/unixfs/pb"
+	ipfscid "github.com/ipfs/go-cid"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// ---------- UnixFS block decoding ----------
+
+// dagLink is a single named link of a dag-pb node
+type dagLink struct {
+	Name  string
+	Cid   ipfscid.Cid
+	Tsize uint64
+}
+
+// unixfsNode is the decoded form of a single UnixFS block (dag-pb or raw leaf)
+type unixfsNode struct {
+	Type       unixfspb.Data_DataType
+	Data       []byte   // inline file bytes (raw leaves and small files)
+	FileSize   uint64   // total size of the file rooted at this node
+	BlockSizes []uint64 // sizes of each child subtree, parallel to Links for files
+	Links      []dagLink
+	Fanout     uint64      // HAMT fanout, only set for sharded directories
+	Mode       os.FileMode // permissions recorded by the importer, 0 if none
+	ModTime    time.Time   // modification time recorded by the importer, zero if none
+}
+
+func (n *unixfsNode) isDir() bool {
+	return n.Type == unixfspb.Data_Directory || n.Type == unixfspb.Data_HAMTShard
+}
+
+// decodeNode decodes a raw block fetched for c into a UnixFS node
+func decodeNode(c ipfscid.Cid, raw []byte) (*unixfsNode, error) {
+	switch multicodec.Code(c.Prefix().Codec) {
+	case multicodec.Raw:
+		return &unixfsNode{Type: unixfspb.Data_Raw, Data: raw, FileSize: uint64(len(raw))}, nil
+	case multicodec.DagPb:
+	default:
+		return nil, fmt.Errorf("unsupported codec %s for %s", multicodec.Code(c.Prefix().Codec), c)
+	}
+
+	nb := dagpb.Type.PBNode.NewBuilder()
+	if err := dagpb.DecodeBytes(nb, raw); err != nil {
+		return nil, fmt.Errorf("decode dag-pb %s: %w", c, err)
+	}
+	pbn := nb.Build().(dagpb.PBNode)
+
+	n := &unixfsNode{}
+	it := pbn.FieldLinks().Iterator()
+	for !it.Done() {
+		_, l := it.Next()
+		lnk, ok := l.FieldHash().Link().(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unexpected link type in %s", c)
+		}
+		dl := dagLink{Cid: lnk.Cid}
+		if l.FieldName().Exists() {
+			dl.Name = l.FieldName().Must().String()
+		}
+		if l.FieldTsize().Exists() {
+			dl.Tsize = uint64(l.FieldTsize().Must().Int())
+		}
+		n.Links = append(n.Links, dl)
+	}
+
+	if !pbn.FieldData().Exists() {
+		return nil, fmt.Errorf("dag-pb node %s has no UnixFS data", c)
+	}
+	fsn, err := unixfs.FSNodeFromBytes(pbn.FieldData().Must().Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("decode UnixFS data %s: %w", c, err)
+	}
+	n.Type = fsn.Type()
+	n.Data = fsn.Data()
+	n.FileSize = fsn.FileSize()
+	n.BlockSizes = fsn.BlockSizes()
+	n.Mode = fsn.Mode().Perm()
+	n.ModTime = fsn.ModTime()
+	if n.Type == unixfspb.Data_HAMTShard {
+		n.Fanout = fsn.Fanout()
+	}
+	return n, nil
+}
+
+// hamtPadLen is the length of the hex bucket prefix HAMT shards put in front of link names
+func hamtPadLen(fanout uint64) int {
+	return len(fmt.Sprintf("%X", fanout-1))
+}
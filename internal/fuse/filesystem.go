@@ -1,283 +1,342 @@
 package fuse
 
 import (
-	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"hash/fnv"
 	"io"
 	"log"
-	"net/http"
+	"os"
 	"path"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/ABD-AZE/StorachaFS/internal/cache"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
-	// "github.com/ABD-AZE/StorachaFS/internal/auth"
+	lru "github.com/hashicorp/golang-lru/v2"
+	unixfspb "github.com/ipfs/boxo/ipld/unixfs/pb"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+	"golang.org/x/sys/unix"
 )
 
 // ---------- Storacha client abstraction ----------
 
 type FileEntry struct {
-	Name string
-	Dir  bool
-	Size uint64
-	CID  string
+	Name  string
+	Dir   bool
+	Size  uint64
+	CID   string
+	Tsize uint64 // cumulative DAG size recorded in the parent's link
+	// Mode and ModTime are the UnixFS metadata of the entry, if it has any
+	Mode    os.FileMode
+	ModTime time.Time
+	// Unavailable marks an entry whose root block is not cached offline;
+	// Dir and Size are then guessed from the link in its parent
+	Unavailable bool
 }
 
-type Tree map[string][]FileEntry // key = dir path ("" for root)
-
 // contract for interacting with IPFS/Storacha content
 type StorachaClient interface {
-	ListTree(cid string) (Tree, error)
-	OpenReader(cid, p string) (io.ReadSeeker, uint64, error)
+	ListDir(cid string) ([]FileEntry, error)
+	OpenFile(cid string) (FileReader, error)
+	Availability(root string) ([]PathStatus, error)
+	Resolve(root, p string) (string, error)
+	Prefetch(root string, concurrency int, progress func(PrefetchProgress)) error
 }
 
-// Real Storacha client implementation
-type storachaClient struct {
-	debug bool
+// number of recently used blocks kept decoded in memory, so sequential reads
+// smaller than a leaf do not refetch it
+const blockMemoryCacheSize = 128
+
+// ClientConfig holds the retrieval settings of a StorachaClient
+type ClientConfig struct {
+	Debug bool
+	// Trustless fetches every block as a CAR and checks it against its CID,
+	// so third-party gateways cannot serve altered content
+	Trustless bool
+	// Gateways are tried in order, unhealthy ones last; DefaultGateway if empty
+	Gateways []string
+	// GatewayTimeout bounds a single request to one gateway
+	GatewayTimeout time.Duration
+	// HedgeAfter sends a duplicate request to the next gateway when the first
+	// has not answered in this long; 0 disables hedging
+	HedgeAfter time.Duration
+	// Cache persists fetched blocks on disk across opens and mounts; nil disables it
+	Cache *cache.BlockCache
+	// Offline serves listings and file bytes solely from Cache; uncached
+	// blocks fail with ErrNotCached instead of being fetched
+	Offline bool
+	// Publisher makes the mount writable: changed files and directories are
+	// uploaded through it on close. nil mounts read-only.
+	Publisher Publisher
+	// StagingDir holds the local copies of files open for writing
+	StagingDir string
+	// Import controls how written files and directories are encoded
+	Import unixfs.Params
 }
 
-func NewStorachaClient(debug bool) StorachaClient {
-	return &storachaClient{debug: debug}
+// Real Storacha client implementation
+type storachaClient struct {
+	debug     bool
+	trustless bool
+	gateways  *gatewayPool
+	blocks    *lru.Cache[string, []byte]
+	disk      *cache.BlockCache
+	offline   bool
+
+	// blocks written through this mount that the disk cache could not hold,
+	// so reading them back never waits on a gateway picking them up
+	writtenMu sync.Mutex
+	written   map[string][]byte
 }
 
-func (c *storachaClient) ListTree(cid string) (Tree, error) {
-	t := make(Tree)
-	err := c.listTreeRecursive(cid, "", t)
-	if err != nil {
-		return nil, err
+func NewStorachaClient(config *ClientConfig) StorachaClient {
+	blocks, _ := lru.New[string, []byte](blockMemoryCacheSize)
+	return &storachaClient{
+		debug:     config.Debug,
+		trustless: config.Trustless,
+		gateways:  newGatewayPool(config.Gateways, config.GatewayTimeout, config.HedgeAfter, config.Debug),
+		blocks:    blocks,
+		disk:      config.Cache,
+		offline:   config.Offline,
+		written:   make(map[string][]byte),
 	}
-	return t, nil
 }
 
-// currently uses html based parsing of the page obtained by querying
-func (c *storachaClient) listTreeRecursive(cid, dirPath string, tree Tree) error {
+// ListDir returns the immediate entries of a single directory, built from the
+// directory's own dag-pb block rather than the gateway's HTML index
+func (c *storachaClient) ListDir(cid string) ([]FileEntry, error) {
 	if c.debug {
-		log.Printf("Listing directory CID %s at path %s", cid, dirPath)
+		log.Printf("Listing directory CID %s", cid)
 	}
 
-	url := "https://storacha.link/ipfs/" + cid + "/"
-	resp, err := http.Get(url)
+	dirCID, err := ipfscid.Decode(cid)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid directory CID %q: %w", cid, err)
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Failed to close response body: %v", err)
-		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
+	node, err := c.getNode(dirCID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !node.isDir() {
+		return nil, fmt.Errorf("CID %s is not a UnixFS directory", cid)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	links, err := c.directoryLinks(node)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var entries []FileEntry
-
-	// First, collect all the direct CID links (these contain the actual file CIDs)
-	cidMap := make(map[string]string) // filename -> CID
-	doc.Find("a.ipfs-hash").Each(func(i int, s *goquery.Selection) {
-		href, _ := s.Attr("href")
-		href = strings.Split(href, "?")[0] // strip query params
-
-		parts := strings.Split(strings.Trim(href, "/"), "/")
-		if len(parts) >= 2 && parts[0] == "ipfs" {
-			cid := parts[1]
-			// Extract filename from the query parameter or link text
-			filename := ""
-			if fullHref, exists := s.Attr("href"); exists && strings.Contains(fullHref, "filename=") {
-				parts := strings.Split(fullHref, "filename=")
-				if len(parts) > 1 {
-					filename = parts[1]
-				}
-			}
-			if filename == "" {
-				filename = strings.TrimSpace(s.Text())
-			}
-			if filename != "" {
-				cidMap[filename] = cid
-			}
-		}
-	})
-
-	// Then, process the file path links to get the filenames
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		// Skip if this is a CID hash link (we already processed these)
-		if s.HasClass("ipfs-hash") {
-			return
+	entries := make([]FileEntry, 0, len(links))
+	for _, l := range links {
+		entry, err := c.statLink(l)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", l.Name, err)
 		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
 
-		href, _ := s.Attr("href")
-		href = strings.Split(href, "?")[0] // strip query params
-		if href == "" || href == "../" {
-			return
-		}
+// directoryLinks returns the named entries of a directory node, flattening HAMT shards
+func (c *storachaClient) directoryLinks(node *unixfsNode) ([]dagLink, error) {
+	return c.directoryLinksWith(node, c.getNode)
+}
 
-		parts := strings.Split(strings.Trim(href, "/"), "/")
-		if len(parts) < 2 || parts[0] != "ipfs" {
-			return
-		}
+// directoryLinksWith is directoryLinks with the block source for sub-shards made explicit
+func (c *storachaClient) directoryLinksWith(node *unixfsNode, get func(ipfscid.Cid) (*unixfsNode, error)) ([]dagLink, error) {
+	if node.Type != unixfspb.Data_HAMTShard {
+		return node.Links, nil
+	}
 
-		// Only process links that have a path component (file path links)
-		// Skip direct CID links that don't have a path
-		if len(parts) < 3 {
-			return
+	padLen := hamtPadLen(node.Fanout)
+	var links []dagLink
+	for _, l := range node.Links {
+		if len(l.Name) < padLen {
+			return nil, fmt.Errorf("malformed HAMT link name %q", l.Name)
 		}
-
-		// Get the actual filename from the link text
-		name := strings.TrimSpace(s.Text())
-		if name == "" || name == "../" {
-			return
+		if len(l.Name) > padLen {
+			// leaf entry: bucket prefix followed by the real name
+			links = append(links, dagLink{Name: l.Name[padLen:], Cid: l.Cid, Tsize: l.Tsize})
+			continue
 		}
-
-		// Get the corresponding CID from our map
-		childCID, exists := cidMap[name]
-		if !exists {
-			// Fallback: use the directory CID (this shouldn't happen in normal cases)
-			childCID = parts[1]
+		// bare bucket prefix: a sub-shard holding more entries
+		sub, err := get(l.Cid)
+		if err != nil {
+			return nil, err
 		}
-
-		isDir := strings.HasSuffix(href, "/")
-
-		// Get file size for non-directory entries
-		var size uint64
-		if !isDir {
-			url := "https://storacha.link/ipfs/" + childCID
-			resp, err := http.Head(url)
-			if err == nil {
-				size = uint64(resp.ContentLength)
-				if err := resp.Body.Close(); err != nil {
-					log.Printf("Failed to close response body: %v", err)
-				}
-			}
+		subLinks, err := c.directoryLinksWith(sub, get)
+		if err != nil {
+			return nil, err
 		}
-
-		entries = append(entries, FileEntry{
-			Name: name,
-			Dir:  isDir,
-			Size: size,
-			CID:  childCID,
-		})
-	})
-
-	tree[dirPath] = entries
-	return nil
+		links = append(links, subLinks...)
+	}
+	return links, nil
 }
 
-// The actual reader logic goes here, This function is called by Open method of StorachaFile
-func (c *storachaClient) OpenReader(cid, p string) (io.ReadSeeker, uint64, error) {
-	if c.debug {
-		log.Printf("Opening file CID %s at path %s", cid, p)
+// statLink resolves the type and size of a directory entry
+func (c *storachaClient) statLink(l dagLink) (FileEntry, error) {
+	entry := FileEntry{Name: l.Name, CID: l.Cid.String(), Tsize: l.Tsize}
+
+	// raw leaves are whole files and their Tsize is the exact byte count
+	if multicodec.Code(l.Cid.Prefix().Codec) == multicodec.Raw {
+		entry.Size = l.Tsize
+		return entry, nil
 	}
 
-	// For individual files, use the CID directly - each file has its own CID in IPFS
-	url := "https://storacha.link/ipfs/" + cid
-	if c.debug {
-		log.Printf("Fetching URL: %s", url)
+	child, err := c.getNode(l.Cid)
+	if errors.Is(err, ErrNotCached) {
+		// one missing child must not hide its cached siblings; it is shown
+		// as a file of its DAG size and fails with ENODATA when opened
+		entry.Size, entry.Unavailable = l.Tsize, true
+		return entry, nil
+	}
+	if err != nil {
+		return FileEntry{}, err
 	}
+	entry.Dir = child.isDir()
+	entry.Mode, entry.ModTime = child.Mode, child.ModTime
+	if !entry.Dir {
+		entry.Size = child.FileSize
+	}
+	return entry, nil
+}
 
-	resp, err := http.Get(url)
+// getNode fetches and decodes a single block
+func (c *storachaClient) getNode(cid ipfscid.Cid) (*unixfsNode, error) {
+	raw, err := c.getBlock(cid)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	return decodeNode(cid, raw)
+}
+
+// getBlock returns a block from memory, then the disk cache, then the network
+func (c *storachaClient) getBlock(cid ipfscid.Cid) ([]byte, error) {
+	if raw, ok := c.blocks.Get(cid.KeyString()); ok {
+		return raw, nil
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Failed to close response body: %v", err)
+	if c.disk != nil {
+		if raw, ok := c.disk.Get(cid); ok {
+			c.blocks.Add(cid.KeyString(), raw)
+			return raw, nil
 		}
-	}()
+	}
+	c.writtenMu.Lock()
+	raw, ok := c.written[cid.KeyString()]
+	c.writtenMu.Unlock()
+	if ok {
+		return raw, nil
+	}
+	if c.offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, cid)
+	}
 
-	data, err := io.ReadAll(resp.Body)
+	var err error
+	if c.trustless {
+		raw, err = c.fetchBlockCAR(cid)
+	} else {
+		raw, err = c.fetchBlock(cid)
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	return newBytesReadSeeker(data), uint64(len(data)), nil
+	c.blocks.Add(cid.KeyString(), raw)
+	if c.disk != nil {
+		if err := c.disk.Put(cid, raw); err != nil {
+			log.Printf("Failed to cache block %s: %v", cid, err)
+		}
+	}
+	return raw, nil
 }
 
-// Simple ReadSeeker over a byte slice
-// Represents in-memory storage of files (only works for small files)
-type bytesRS struct {
-	b   []byte
-	off int64
+// keep makes a locally produced block readable through this client
+func (c *storachaClient) keep(b blocks.Block) {
+	if c.disk != nil {
+		err := c.disk.Put(b.Cid(), b.RawData())
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to cache written block %s: %v", b.Cid(), err)
+	}
+	c.writtenMu.Lock()
+	c.written[b.Cid().KeyString()] = b.RawData()
+	c.writtenMu.Unlock()
 }
 
-func newBytesReadSeeker(b []byte) *bytesRS {
-	return &bytesRS{b: b}
+// fetchBlock retrieves the raw bytes of a single block from the gateway pool
+func (c *storachaClient) fetchBlock(cid ipfscid.Cid) ([]byte, error) {
+	data, err := c.gateways.fetch("/ipfs/"+cid.String()+"?format=raw", "application/vnd.ipld.raw", nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch block %s: %w", cid, err)
+	}
+	return data, nil
 }
 
-// This is the method called by OS when cat or other command reads the file
-func (r *bytesRS) Read(p []byte) (int, error) {
-	if r.off >= int64(len(r.b)) {
-		return 0, io.EOF
+// OpenFile fetches only the root block of a file; the returned reader pulls
+// the remaining blocks on demand as ranges are read
+func (c *storachaClient) OpenFile(cid string) (FileReader, error) {
+	if c.debug {
+		log.Printf("Opening file CID %s", cid)
 	}
-	n := copy(p, r.b[r.off:])
-	r.off += int64(n)
-	return n, nil
-}
 
-// This is the method called by OS when cat or other command seeks the file
-func (r *bytesRS) Seek(off int64, whence int) (int64, error) {
-	var n int64
-	switch whence {
-	case io.SeekStart:
-		n = off
-	case io.SeekCurrent:
-		n = r.off + off
-	case io.SeekEnd:
-		n = int64(len(r.b)) + off
-	default:
-		return 0, errors.New("bad whence")
+	fileCID, err := ipfscid.Decode(cid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file CID %q: %w", cid, err)
 	}
-	if n < 0 {
-		return 0, errors.New("negative position")
+	root, err := c.getNode(fileCID)
+	if err != nil {
+		return nil, err
 	}
-	r.off = n
-	return n, nil
+	if root.isDir() {
+		return nil, fmt.Errorf("CID %s is a directory", cid)
+	}
+	return &dagReader{c: c, cid: fileCID, root: root}, nil
 }
 
 // ---------- go-fuse nodes ----------
 
 // StorachaFS is the root node of the filesystem
 type StorachaFS struct {
-	fs.Inode
-	cid    string
-	client StorachaClient
-	tree   Tree
-	debug  bool
+	StorachaDir
 }
 
-func NewStorachaFS(rootCID string, debug bool) *StorachaFS {
-	client := NewStorachaClient(debug)
-	tree, err := client.ListTree(rootCID)
-	if err != nil {
-		log.Printf("Failed to list tree for CID %s: %v", rootCID, err)
-		tree = make(Tree) // Empty tree on error
+// NewStorachaFS creates the root node; directory listings are fetched lazily
+// as they are visited. The tree is writable if config has a Publisher.
+func NewStorachaFS(rootCID string, config *ClientConfig) *StorachaFS {
+	client := NewStorachaClient(config)
+	var w *mountWriter
+	if config.Publisher != nil {
+		w = &mountWriter{
+			client:     client.(*storachaClient),
+			publisher:  config.Publisher,
+			stagingDir: config.StagingDir,
+			params:     config.Import,
+			debug:      config.Debug,
+		}
 	}
 	return &StorachaFS{
-		cid:    rootCID,
-		client: client,
-		tree:   tree,
-		debug:  debug,
+		StorachaDir: StorachaDir{
+			cid:    rootCID,
+			client: client,
+			w:      w,
+			debug:  config.Debug,
+		},
 	}
 }
 
-var _ = (fs.NodeLookuper)((*StorachaFS)(nil))
-var _ = (fs.NodeReaddirer)((*StorachaFS)(nil))
-var _ = (fs.NodeGetattrer)((*StorachaFS)(nil))
 var _ = (fs.NodeStatfser)((*StorachaFS)(nil))
 
-func (r *StorachaFS) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = fuse.S_IFDIR | 0555
-	return 0
+// Client returns the client the filesystem retrieves content with
+func (r *StorachaFS) Client() StorachaClient {
+	return r.client
 }
 
 // currently contains fake values
@@ -291,23 +350,22 @@ func (r *StorachaFS) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Er
 	return 0
 }
 
-func (r *StorachaFS) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	return lookupCommon(ctx, &r.Inode, r.cid, r.client, r.tree, "", name, out, r.debug)
-}
-
-func (r *StorachaFS) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	list := r.tree[""]
-	return dirStreamFrom(list), 0
-}
-
-// StorachaDir is a directory sub-node
+// StorachaDir is a directory node; the root embeds one with dir == ""
 type StorachaDir struct {
 	fs.Inode
 	cid    string
 	client StorachaClient
-	tree   Tree
-	dir    string // path from root, "" for root
+	w      *mountWriter // nil on read-only mounts
 	debug  bool
+
+	mu      sync.Mutex
+	entries []FileEntry // memoized listing of cid, nil until first visited
+}
+
+// dir is the path of d from the root, "" for the root. It is read from the
+// inode tree, so it follows renames of d and of its ancestors.
+func (d *StorachaDir) dir() string {
+	return d.Path(nil)
 }
 
 var _ = (fs.NodeLookuper)((*StorachaDir)(nil))
@@ -315,160 +373,542 @@ var _ = (fs.NodeReaddirer)((*StorachaDir)(nil))
 var _ = (fs.NodeGetattrer)((*StorachaDir)(nil))
 
 func (d *StorachaDir) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = fuse.S_IFDIR | 0555
+	out.Mode = fuse.S_IFDIR | d.w.perm(true)
 	return 0
 }
 
 func (d *StorachaDir) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	return lookupCommon(ctx, &d.Inode, d.cid, d.client, d.tree, d.dir, name, out, d.debug)
+	entries, errno := d.list()
+	if errno != 0 {
+		return nil, errno
+	}
+	return lookupCommon(ctx, d, entries, name, out)
 }
 
 func (d *StorachaDir) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
-	list := d.tree[d.dir]
-	return dirStreamFrom(list), 0
+	entries, errno := d.list()
+	if errno != 0 {
+		return nil, errno
+	}
+	return dirStreamFrom(d.dir(), entries), 0
+}
+
+// list fetches the directory's own listing on first use and memoizes it;
+// failures are not memoized so a later visit retries
+func (d *StorachaDir) list() ([]FileEntry, syscall.Errno) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries != nil {
+		return d.entries, 0
+	}
+
+	entries, err := d.client.ListDir(d.cid)
+	if errors.Is(err, ErrNotCached) {
+		log.Printf("Directory %q is not fully cached: %v", "/"+d.dir(), err)
+		return nil, syscall.ENODATA
+	}
+	if err != nil {
+		log.Printf("Failed to list directory %q (CID %s): %v", "/"+d.dir(), d.cid, err)
+		return nil, syscall.EIO
+	}
+	if entries == nil {
+		entries = []FileEntry{}
+	}
+	d.entries = entries
+	return entries, 0
 }
 
 // StorachaFile is a file sub-node
 type StorachaFile struct {
 	fs.Inode
-	cid    string
 	client StorachaClient
-	path   string
-	size   uint64
+	w      *mountWriter
 	debug  bool
+
+	mu   sync.Mutex
+	cid  string // current content, replaced when a write is committed
+	size uint64
+}
+
+// path is the path of f from the root, read from the inode tree like
+// StorachaDir.dir; a file removed while open is "/"
+func (f *StorachaFile) path() string {
+	return "/" + f.Path(nil)
 }
 
 var _ = (fs.NodeGetattrer)((*StorachaFile)(nil))
 var _ = (fs.NodeOpener)((*StorachaFile)(nil))
+var _ = (fs.NodeSetattrer)((*StorachaFile)(nil))
 
-func (f *StorachaFile) Getattr(ctx context.Context, h fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
-	out.Mode = fuse.S_IFREG | 0444
+func (f *StorachaFile) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	f.mu.Lock()
 	out.Size = f.size
+	f.mu.Unlock()
+	if h, ok := fh.(*fileHandle); ok && h.staging != nil {
+		// unflushed writes are visible to the writer
+		if info, err := h.staging.Stat(); err == nil {
+			out.Size = uint64(info.Size())
+		}
+	}
+	out.Mode = fuse.S_IFREG | f.w.perm(false)
 	out.Mtime = uint64(time.Now().Unix())
 	out.Atime = out.Mtime
 	out.Ctime = out.Mtime
 	return 0
 }
 
+// Setattr supports truncation; mode, owner and time changes are accepted but not stored
+func (f *StorachaFile) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if size, ok := in.GetSize(); ok {
+		if f.w == nil {
+			return syscall.EROFS
+		}
+		if h, ok := fh.(*fileHandle); ok && h.staging != nil {
+			if errno := h.truncate(int64(size)); errno != 0 {
+				return errno
+			}
+		} else if errno := f.truncate(ctx, int64(size)); errno != 0 {
+			return errno
+		}
+	}
+	return f.Getattr(ctx, fh, out)
+}
+
+// truncate resizes a file that is not open for writing and commits it right away
+func (f *StorachaFile) truncate(ctx context.Context, size int64) syscall.Errno {
+	tmp, err := f.w.stage(f, size == 0)
+	if err != nil {
+		log.Printf("Failed to truncate %s: %v", f.path(), err)
+		return syscall.EIO
+	}
+	defer discardStaging(tmp)
+	if err := tmp.Truncate(size); err != nil {
+		log.Printf("Failed to truncate %s: %v", f.path(), err)
+		return syscall.EIO
+	}
+	if err := f.w.commitFile(ctx, f, tmp, size); err != nil {
+		log.Printf("Failed to commit %s: %v", f.path(), err)
+		return syscall.EIO
+	}
+	return 0
+}
+
 func (f *StorachaFile) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
-	rs, size, err := f.client.OpenReader(f.cid, f.path)
+	if flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return f.openWritable(flags)
+	}
+
+	f.mu.Lock()
+	cid := f.cid
+	f.mu.Unlock()
+	r, err := f.client.OpenFile(cid)
+	if errors.Is(err, ErrNotCached) {
+		log.Printf("Cannot open %s offline: %v", f.path(), err)
+		return nil, 0, syscall.ENODATA
+	}
 	if err != nil {
-		return nil, 0, fs.ToErrno(err)
+		log.Printf("Failed to open %s (CID %s): %v", f.path(), cid, err)
+		return nil, 0, syscall.EIO
+	}
+	if f.w != nil {
+		// content can change under a writable mount
+		return &fileHandle{r: r, file: f}, 0, 0
 	}
-	return &fileHandle{rs: rs, size: size}, fuse.FOPEN_KEEP_CACHE, 0
+	return &fileHandle{r: r, file: f}, fuse.FOPEN_KEEP_CACHE, 0
 }
 
-// Represents an open file handle with downloaded content.
+// openWritable stages the file locally; the staged copy is uploaded on flush
+func (f *StorachaFile) openWritable(flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if f.w == nil {
+		return nil, 0, syscall.EROFS
+	}
+	truncate := flags&syscall.O_TRUNC != 0
+	tmp, err := f.w.stage(f, truncate)
+	if errors.Is(err, ErrNotCached) {
+		log.Printf("Cannot open %s for writing offline: %v", f.path(), err)
+		return nil, 0, syscall.ENODATA
+	}
+	if err != nil {
+		log.Printf("Failed to open %s for writing: %v", f.path(), err)
+		return nil, 0, syscall.EIO
+	}
+	return &fileHandle{r: nil, file: f, staging: tmp, dirty: truncate}, 0, 0
+}
+
+// Represents an open file handle. Read-only handles fetch bytes per read,
+// never the whole file; writable handles work on a local staging copy.
 type fileHandle struct {
 	fs.FileHandle
-	rs   io.ReadSeeker
-	size uint64
+	r    FileReader
+	file *StorachaFile
+
+	mu      sync.Mutex
+	staging *os.File // nil for read-only handles
+	dirty   bool     // staging differs from the committed content
 }
 
 var _ = (fs.FileReader)((*fileHandle)(nil))
+var _ = (fs.FileWriter)((*fileHandle)(nil))
+var _ = (fs.FileFlusher)((*fileHandle)(nil))
+var _ = (fs.FileFsyncer)((*fileHandle)(nil))
+var _ = (fs.FileReleaser)((*fileHandle)(nil))
 
 func (h *fileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
-	if _, err := h.rs.Seek(off, io.SeekStart); err != nil {
-		return nil, fs.ToErrno(err)
+	var n int
+	var err error
+	if h.staging != nil {
+		h.mu.Lock()
+		n, err = h.staging.ReadAt(dest, off)
+		h.mu.Unlock()
+	} else {
+		n, err = h.r.ReadAt(dest, off)
 	}
-	n, err := io.ReadFull(h.rs, dest)
-	if err == io.ErrUnexpectedEOF || err == io.EOF {
-		// Partial read is fine
+	if err == io.EOF {
+		// Partial read at end of file is fine
 		return fuse.ReadResultData(dest[:n]), 0
 	}
+	if errors.Is(err, ErrBlockVerification) {
+		log.Printf("Refusing to serve %s at offset %d: %v", h.file.path(), off, err)
+		return nil, syscall.EIO
+	}
+	if errors.Is(err, ErrNotCached) {
+		log.Printf("Cannot read %s at offset %d offline: %v", h.file.path(), off, err)
+		return nil, syscall.ENODATA
+	}
 	if err != nil {
-		return nil, fs.ToErrno(err)
+		log.Printf("Failed to read %s at offset %d: %v", h.file.path(), off, err)
+		return nil, syscall.EIO
 	}
 	return fuse.ReadResultData(dest[:n]), 0
 }
 
-// // -------------------- write methods --------------------
+func (h *fileHandle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	if h.staging == nil {
+		return 0, syscall.EBADF
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.staging.WriteAt(data, off)
+	if n > 0 {
+		h.dirty = true
+	}
+	if err != nil {
+		log.Printf("Failed to stage write to %s at offset %d: %v", h.file.path(), off, err)
+		return uint32(n), syscall.EIO
+	}
+	return uint32(n), 0
+}
+
+func (h *fileHandle) truncate(size int64) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.staging.Truncate(size); err != nil {
+		log.Printf("Failed to truncate %s: %v", h.file.path(), err)
+		return syscall.EIO
+	}
+	h.dirty = true
+	return 0
+}
+
+// Flush runs on every close of the handle and uploads pending writes
+func (h *fileHandle) Flush(ctx context.Context) syscall.Errno {
+	return h.commit(ctx)
+}
+
+func (h *fileHandle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return h.commit(ctx)
+}
+
+func (h *fileHandle) commit(ctx context.Context) syscall.Errno {
+	if h.staging == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.dirty {
+		return 0
+	}
+	info, err := h.staging.Stat()
+	if err != nil {
+		log.Printf("Failed to stat staging file for %s: %v", h.file.path(), err)
+		return syscall.EIO
+	}
+	if err := h.file.w.commitFile(ctx, h.file, h.staging, info.Size()); err != nil {
+		log.Printf("Failed to upload %s: %v", h.file.path(), err)
+		return syscall.EIO
+	}
+	h.dirty = false
+	return 0
+}
 
-// // For file creation and modification
-// var _ = (fs.NodeCreater)((*StorachaDir)(nil))
-// var _ = (fs.NodeMkdirer)((*StorachaDir)(nil))
-// var _ = (fs.NodeUnlinker)((*StorachaDir)(nil))
-// var _ = (fs.NodeRmdirer)((*StorachaDir)(nil))
+func (h *fileHandle) Release(ctx context.Context) syscall.Errno {
+	if h.staging == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.dirty {
+		log.Printf("Discarding writes to %s that were never uploaded", h.file.path())
+	}
+	discardStaging(h.staging)
+	return 0
+}
+
+// -------------------- write methods --------------------
 
-// func (d *StorachaDir) Create(ctx context.Context, name string, mode uint32, umask uint32, flags uint32) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
-//     if d.client == nil{
-//        d.client = auth.CachedClients["pk"] || auth.CachedClients["email"]
-//     }
-// }
+var _ = (fs.NodeCreater)((*StorachaDir)(nil))
 
-// func (d *StorachaDir) Mkdir(ctx context.Context, name string, mode uint32, umask uint32) (*fs.Inode, uint32, syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+func (d *StorachaDir) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if d.w == nil {
+		return nil, nil, 0, syscall.EROFS
+	}
+	// checked and linked under w.mu, so two creates of name cannot both succeed
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
 
-// }
+	if _, errno := d.entry(name); errno != syscall.ENOENT {
+		if errno == 0 {
+			return nil, nil, 0, syscall.EEXIST
+		}
+		return nil, nil, 0, errno
+	}
 
-// func (d *StorachaDir) Unlink(ctx context.Context, name string) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+	full := path.Join(d.dir(), name)
+	f := &StorachaFile{client: d.client, w: d.w, debug: d.debug}
+	// staged before the file is linked, so a failure leaves d as it was
+	tmp, err := d.w.stage(f, true)
+	if err != nil {
+		log.Printf("Failed to create /%s: %v", full, err)
+		return nil, nil, 0, syscall.EIO
+	}
+	entry, err := d.w.addEmptyFile(d, name)
+	if err != nil {
+		discardStaging(tmp)
+		log.Printf("Failed to create /%s: %v", full, err)
+		return nil, nil, 0, syscall.EIO
+	}
+	f.cid = entry.CID
 
-// }
+	out.Mode = fuse.S_IFREG | d.w.perm(false)
+	ch := d.NewInode(ctx, f, fs.StableAttr{Mode: syscall.S_IFREG, Ino: hashInode(entry.CID + "/" + full)})
+	// dirty so that even an empty new file is uploaded when it is closed
+	return ch, &fileHandle{file: f, staging: tmp, dirty: true}, 0, 0
+}
 
-// func (d *StorachaDir) Rmdir(ctx context.Context, name string) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+var _ = (fs.NodeMkdirer)((*StorachaDir)(nil))
+var _ = (fs.NodeUnlinker)((*StorachaDir)(nil))
+var _ = (fs.NodeRmdirer)((*StorachaDir)(nil))
+var _ = (fs.NodeRenamer)((*StorachaDir)(nil))
+var _ = (fs.NodeSetattrer)((*StorachaDir)(nil))
 
-// }
+func (d *StorachaDir) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if d.w == nil {
+		return nil, syscall.EROFS
+	}
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
 
-// func (d *StorachaDir) Rename(ctx context.Context, name string, newParent *fs.Inode, newName string, flags uint32) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+	if _, errno := d.entry(name); errno != syscall.ENOENT {
+		if errno == 0 {
+			return nil, syscall.EEXIST
+		}
+		return nil, errno
+	}
 
-// }
+	var entry FileEntry
+	if errno := d.w.publishEdits(ctx, "mkdir "+path.Join("/"+d.dir(), name), map[*StorachaDir]dirEdit{d: func(entries []FileEntry) ([]FileEntry, error) {
+		return append(entries, entry), nil
+	}}, func(sink unixfs.BlockSink) error {
+		link, err := unixfs.BuildDirectory(nil, d.w.params, sink)
+		entry = FileEntry{Name: name, Dir: true, CID: link.Cid.String(), Tsize: link.Tsize}
+		return err
+	}); errno != 0 {
+		return nil, errno
+	}
 
-// var _ = (fs.FileWriter)((*StorachaFile)(nil))
-// var _ = (fs.FileFlusher)((*StorachaFile)(nil))
-// var _ = (fs.FileReleaser)((*StorachaFile)(nil))
-// var _ = (fs.FileFsyncer)((*StorachaFile)(nil))
+	full := path.Join(d.dir(), name)
+	out.Mode = fuse.S_IFDIR | d.w.perm(true)
+	child := &StorachaDir{cid: entry.CID, client: d.client, w: d.w, debug: d.debug, entries: []FileEntry{}}
+	return d.NewInode(ctx, child, fs.StableAttr{Mode: syscall.S_IFDIR, Ino: hashInode(entry.CID + "/" + full)}), 0
+}
 
-// func (f *StorachaFile) Write(ctx context.Context, data []byte, off int64) (int, syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+func (d *StorachaDir) Unlink(ctx context.Context, name string) syscall.Errno {
+	if d.w == nil {
+		return syscall.EROFS
+	}
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
 
-// }
+	e, errno := d.entry(name)
+	if errno != 0 {
+		return errno
+	}
+	if e.Dir {
+		return syscall.EISDIR
+	}
+	return d.w.publishEdits(ctx, "unlink "+path.Join("/"+d.dir(), name), map[*StorachaDir]dirEdit{d: func(entries []FileEntry) ([]FileEntry, error) {
+		return removeEntry(entries, name), nil
+	}}, nil)
+}
 
-// func (f *StorachaFile) Flush(ctx context.Context) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+func (d *StorachaDir) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if d.w == nil {
+		return syscall.EROFS
+	}
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
 
-// }
+	e, errno := d.entry(name)
+	if errno != 0 {
+		return errno
+	}
+	if !e.Dir {
+		return syscall.ENOTDIR
+	}
+	if errno := d.checkEmpty(e); errno != 0 {
+		return errno
+	}
+	return d.w.publishEdits(ctx, "rmdir "+path.Join("/"+d.dir(), name), map[*StorachaDir]dirEdit{d: func(entries []FileEntry) ([]FileEntry, error) {
+		return removeEntry(entries, name), nil
+	}}, nil)
+}
 
-// func (f *StorachaFile) Release(ctx context.Context) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+// Rename moves an entry within or between directories as a single new root
+func (d *StorachaDir) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if d.w == nil {
+		return syscall.EROFS
+	}
+	if flags&fs.RENAME_EXCHANGE != 0 {
+		return syscall.ENOTSUP
+	}
+	dst := asDir(newParent.EmbeddedInode())
+	if dst == nil {
+		return syscall.EXDEV
+	}
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
 
-// }
+	e, errno := d.entry(name)
+	if errno != 0 {
+		return errno
+	}
+	switch existing, errno := dst.entry(newName); errno {
+	case syscall.ENOENT:
+	case 0:
+		if flags&unix.RENAME_NOREPLACE != 0 {
+			return syscall.EEXIST
+		}
+		if existing.Dir && !e.Dir {
+			return syscall.EISDIR
+		}
+		if !existing.Dir && e.Dir {
+			return syscall.ENOTDIR
+		}
+		if existing.Dir {
+			if errno := dst.checkEmpty(existing); errno != 0 {
+				return errno
+			}
+		}
+	default:
+		return errno
+	}
 
-// func (f *StorachaFile) Fsync(ctx context.Context, flags int) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+	moved := e
+	moved.Name = newName
+	edits := map[*StorachaDir]dirEdit{}
+	if dst == d {
+		edits[d] = func(entries []FileEntry) ([]FileEntry, error) {
+			return replaceEntry(removeEntry(entries, name), newName, moved), nil
+		}
+	} else {
+		edits[d] = func(entries []FileEntry) ([]FileEntry, error) {
+			return removeEntry(entries, name), nil
+		}
+		edits[dst] = func(entries []FileEntry) ([]FileEntry, error) {
+			return replaceEntry(entries, newName, moved), nil
+		}
+	}
+	from, to := path.Join("/"+d.dir(), name), path.Join("/"+dst.dir(), newName)
+	// the inode is moved by go-fuse, and the paths of it and its
+	// descendants follow since they are read from the inode tree
+	return d.w.publishEdits(ctx, "rename "+from+" to "+to, edits, nil)
+}
 
-// }
+// Setattr accepts mode, owner and time changes without storing them, so
+// tools like rsync -a and cp -p can write into the mount
+func (d *StorachaDir) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if d.w == nil {
+		return syscall.EROFS
+	}
+	return d.Getattr(ctx, fh, out)
+}
 
-// func (f *StorachaFile) Setattr(ctx context.Context, attr *fuse.SetAttrIn, out *fuse.AttrOut) (syscall.Errno) {
-//     client , _ = auth.EmailAuth(email)
+// entry returns the current entry called name
+func (d *StorachaDir) entry(name string) (FileEntry, syscall.Errno) {
+	entries, errno := d.list()
+	if errno != 0 {
+		return FileEntry{}, errno
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, 0
+		}
+	}
+	return FileEntry{}, syscall.ENOENT
+}
 
-// }
+// checkEmpty returns ENOTEMPTY unless the subdirectory e has no entries
+func (d *StorachaDir) checkEmpty(e FileEntry) syscall.Errno {
+	var entries []FileEntry
+	if ch := d.GetChild(e.Name); ch != nil && asDir(ch) != nil {
+		var errno syscall.Errno
+		if entries, errno = asDir(ch).list(); errno != 0 {
+			return errno
+		}
+	} else {
+		var err error
+		if entries, err = d.client.ListDir(e.CID); err != nil {
+			log.Printf("Failed to list %s: %v", path.Join("/"+d.dir(), e.Name), err)
+			return syscall.EIO
+		}
+	}
+	if len(entries) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	return 0
+}
 
 // ---------- helpers ----------
 
-func lookupCommon(ctx context.Context, parent *fs.Inode, cid string, client StorachaClient, tree Tree, dir, name string, out *fuse.EntryOut, debug bool) (*fs.Inode, syscall.Errno) {
-	full := path.Join(dir, name)
-	entries := tree[dir]
+func lookupCommon(ctx context.Context, parent *StorachaDir, entries []FileEntry, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	full := path.Join(parent.dir(), name)
 	for _, e := range entries {
 		if e.Name != name {
 			continue
 		}
+		// a known child keeps its inode even if writes have changed its CID
+		if ch := parent.GetChild(name); ch != nil && ch.IsDir() == e.Dir {
+			var attr fuse.AttrOut
+			if n, ok := ch.Operations().(fs.NodeGetattrer); ok {
+				n.Getattr(ctx, nil, &attr)
+			}
+			out.Attr = attr.Attr
+			return ch, 0
+		}
 		if e.Dir {
-			out.Mode = fuse.S_IFDIR | 0555
-			ch := parent.NewInode(ctx, &StorachaDir{cid: e.CID, client: client, tree: tree, dir: full, debug: debug}, fs.StableAttr{Mode: syscall.S_IFDIR, Ino: hashInode(e.CID + "/" + full)})
+			out.Mode = fuse.S_IFDIR | parent.w.perm(true)
+			ch := parent.NewInode(ctx, &StorachaDir{cid: e.CID, client: parent.client, w: parent.w, debug: parent.debug}, fs.StableAttr{Mode: syscall.S_IFDIR, Ino: hashInode(e.CID + "/" + full)})
 			return ch, 0
 		}
-		out.Mode = fuse.S_IFREG | 0444
+		out.Mode = fuse.S_IFREG | parent.w.perm(false)
 		out.Size = e.Size
-		ch := parent.NewInode(ctx, &StorachaFile{cid: e.CID, client: client, path: "/" + full, size: e.Size, debug: debug}, fs.StableAttr{Mode: syscall.S_IFREG, Ino: hashInode(e.CID + "/" + full)})
+		ch := parent.NewInode(ctx, &StorachaFile{cid: e.CID, client: parent.client, w: parent.w, size: e.Size, debug: parent.debug}, fs.StableAttr{Mode: syscall.S_IFREG, Ino: hashInode(e.CID + "/" + full)})
 		return ch, 0
 	}
 	return nil, syscall.ENOENT
 }
 
-func dirStreamFrom(list []FileEntry) fs.DirStream {
+func dirStreamFrom(dir string, list []FileEntry) fs.DirStream {
 	var dirents []fuse.DirEntry
 	for _, e := range list {
 		mode := uint32(fuse.S_IFREG)
@@ -478,7 +918,7 @@ func dirStreamFrom(list []FileEntry) fs.DirStream {
 		dirents = append(dirents, fuse.DirEntry{
 			Mode: mode,
 			Name: e.Name,
-			Ino:  hashInode(e.CID + "/" + e.Name),
+			Ino:  hashInode(e.CID + "/" + path.Join(dir, e.Name)),
 		})
 	}
 	return fs.NewListDirStream(dirents)
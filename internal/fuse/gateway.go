@@ -0,0 +1,217 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGateway is used when no gateway is configured
+const DefaultGateway = "https://storacha.link"
+
+// how long a gateway is skipped after consecutive failures, doubling per failure
+const (
+	gatewayBackoffBase = time.Second
+	gatewayBackoffMax  = time.Minute
+)
+
+// gateway is a single IPFS HTTP gateway with its health record
+type gateway struct {
+	base string // scheme and host, without the /ipfs/ suffix
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+}
+
+func (g *gateway) healthy(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !now.Before(g.downUntil)
+}
+
+func (g *gateway) markSuccess() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = 0
+	g.downUntil = time.Time{}
+}
+
+// markFailure backs the gateway off exponentially so requests fail over to
+// the others until it has had time to recover
+func (g *gateway) markFailure() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	backoff := gatewayBackoffBase << g.failures
+	if backoff > gatewayBackoffMax || backoff <= 0 {
+		backoff = gatewayBackoffMax
+	} else {
+		g.failures++
+	}
+	g.downUntil = time.Now().Add(backoff)
+	return backoff
+}
+
+// gatewayPool spreads retrieval over several gateways, failing over on
+// 5xx/timeouts and optionally hedging slow requests to a second gateway
+type gatewayPool struct {
+	gateways   []*gateway
+	httpClient *http.Client
+	hedgeAfter time.Duration // 0 disables hedging
+	debug      bool
+}
+
+func newGatewayPool(urls []string, timeout, hedgeAfter time.Duration, debug bool) *gatewayPool {
+	if len(urls) == 0 {
+		urls = []string{DefaultGateway}
+	}
+	p := &gatewayPool{
+		httpClient: &http.Client{Timeout: timeout},
+		hedgeAfter: hedgeAfter,
+		debug:      debug,
+	}
+	for _, u := range urls {
+		base := strings.TrimSuffix(strings.TrimSuffix(u, "/"), "/ipfs")
+		p.gateways = append(p.gateways, &gateway{base: base})
+	}
+	return p
+}
+
+// candidates returns the gateways in configured order, healthy ones first
+func (p *gatewayPool) candidates() []*gateway {
+	now := time.Now()
+	var up, down []*gateway
+	for _, g := range p.gateways {
+		if g.healthy(now) {
+			up = append(up, g)
+		} else {
+			down = append(down, g)
+		}
+	}
+	return append(up, down...)
+}
+
+type fetchResult struct {
+	data []byte
+	err  error
+}
+
+// fetch GETs urlPath (e.g. "/ipfs/<cid>?format=raw") and returns the full body
+// from the first gateway that answers with 200. If extract is set, the body is
+// passed through it and a gateway whose body fails extraction counts as failed.
+func (p *gatewayPool) fetch(urlPath, accept string, extract func(body []byte) ([]byte, error)) ([]byte, error) {
+	candidates := p.candidates()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan fetchResult, len(candidates))
+	next, inflight := 0, 0
+	launch := func() {
+		g := candidates[next]
+		next++
+		inflight++
+		go func() {
+			data, err := p.try(ctx, g, urlPath, accept, extract)
+			results <- fetchResult{data: data, err: err}
+		}()
+	}
+
+	launch()
+	var lastErr error
+	for inflight > 0 {
+		var hedge <-chan time.Time
+		var timer *time.Timer
+		if p.hedgeAfter > 0 && next < len(candidates) {
+			timer = time.NewTimer(p.hedgeAfter)
+			hedge = timer.C
+		}
+
+		select {
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				if timer != nil {
+					timer.Stop()
+				}
+				return r.data, nil
+			}
+			lastErr = r.err
+			if next < len(candidates) {
+				launch()
+			}
+		case <-hedge:
+			if p.debug {
+				log.Printf("No response after %s, hedging %s to %s", p.hedgeAfter, urlPath, candidates[next].base)
+			}
+			launch()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return nil, lastErr
+}
+
+// try performs a single request against one gateway and updates its health
+func (p *gatewayPool) try(ctx context.Context, g *gateway, urlPath, accept string, extract func([]byte) ([]byte, error)) ([]byte, error) {
+	url := g.base + urlPath
+	if p.debug {
+		log.Printf("Fetching URL: %s", url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		p.fail(ctx, g, err)
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		err := fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+		p.fail(ctx, g, err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// the gateway is up, it just cannot serve this request
+		return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		p.fail(ctx, g, err)
+		return nil, err
+	}
+	if extract != nil {
+		if data, err = extract(data); err != nil {
+			p.fail(ctx, g, err)
+			return nil, err
+		}
+	}
+	g.markSuccess()
+	return data, nil
+}
+
+// fail records a failure unless the request was cancelled because a hedged
+// request to another gateway already won
+func (p *gatewayPool) fail(ctx context.Context, g *gateway, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	backoff := g.markFailure()
+	log.Printf("Gateway %s failed (%v); skipping it for %s", g.base, err, backoff)
+}
@@ -0,0 +1,130 @@
+package fuse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testPath = "/ipfs/bafkqaaa?format=raw"
+
+// testGateway serves every request with handler and counts them
+type testGateway struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newTestGateway(t *testing.T, handler http.HandlerFunc) *testGateway {
+	t.Helper()
+	g := &testGateway{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.requests.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(code), code)
+	}
+}
+
+func serve(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() != testPath || r.Header.Get("Accept") != "application/vnd.ipld.raw" {
+			http.Error(w, "unexpected request "+r.URL.RequestURI(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestGatewayPoolFetch(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       http.HandlerFunc
+		primaryFailed bool // the primary is backed off afterwards
+	}{
+		{"server error fails over", status(http.StatusBadGateway), true},
+		{"not found fails over", status(http.StatusNotFound), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newTestGateway(t, tt.primary)
+			secondary := newTestGateway(t, serve("block"))
+			p := newGatewayPool([]string{primary.URL, secondary.URL}, time.Second, 0, false)
+
+			data, err := p.fetch(testPath, "application/vnd.ipld.raw", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "block" {
+				t.Errorf("fetched %q, want %q", data, "block")
+			}
+			if n := primary.requests.Load(); n != 1 {
+				t.Errorf("primary got %d requests, want 1", n)
+			}
+			if healthy := p.gateways[0].healthy(time.Now()); healthy == tt.primaryFailed {
+				t.Errorf("primary healthy = %v, want %v", healthy, !tt.primaryFailed)
+			}
+			if tt.primaryFailed && p.candidates()[0] != p.gateways[1] {
+				t.Error("backed off primary is still tried first")
+			}
+		})
+	}
+}
+
+func TestGatewayPoolHedgesSlowPrimary(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	primary := newTestGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	secondary := newTestGateway(t, serve("block"))
+	p := newGatewayPool([]string{primary.URL, secondary.URL}, 10*time.Second, 20*time.Millisecond, false)
+
+	start := time.Now()
+	data, err := p.fetch(testPath, "application/vnd.ipld.raw", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "block" {
+		t.Errorf("fetched %q, want %q", data, "block")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetch took %s, the hedge did not fire", elapsed)
+	}
+	if n := secondary.requests.Load(); n != 1 {
+		t.Errorf("secondary got %d requests, want 1 hedged request", n)
+	}
+	if !p.gateways[0].healthy(time.Now()) {
+		t.Error("slow primary backed off although the hedge won")
+	}
+}
+
+func TestGatewayPoolAllDown(t *testing.T) {
+	first := newTestGateway(t, status(http.StatusServiceUnavailable))
+	last := newTestGateway(t, status(http.StatusInternalServerError))
+	p := newGatewayPool([]string{first.URL, last.URL}, time.Second, 0, false)
+
+	_, err := p.fetch(testPath, "application/vnd.ipld.raw", nil)
+	if err == nil {
+		t.Fatal("fetch succeeded with every gateway down")
+	}
+	if !strings.Contains(err.Error(), last.URL) || !strings.Contains(err.Error(), "500") {
+		t.Errorf("error %q is not the last gateway's", err)
+	}
+	for i, g := range p.gateways {
+		if g.healthy(time.Now()) {
+			t.Errorf("gateway %d not backed off", i)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package fuse
+
+import (
+	"errors"
+	"path"
+
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// ErrNotCached is returned in offline mode for blocks that are not in the local cache
+var ErrNotCached = errors.New("block not available offline")
+
+// PathStatus reports whether everything under a path can be served from the local cache
+type PathStatus struct {
+	Path     string
+	Dir      bool
+	Complete bool
+}
+
+// Availability walks root using only the local cache and reports, for every
+// path that can be discovered, whether all of its blocks are cached. Paths
+// below an uncached directory block cannot be discovered and are not listed.
+func (c *storachaClient) Availability(root string) ([]PathStatus, error) {
+	rootCID, err := ipfscid.Decode(root)
+	if err != nil {
+		return nil, err
+	}
+	var out []PathStatus
+	c.dirAvailability(rootCID, "/", &out)
+	return out, nil
+}
+
+// dirAvailability appends the status of dir and everything below it, and
+// returns whether the whole subtree is cached
+func (c *storachaClient) dirAvailability(cid ipfscid.Cid, dir string, out *[]PathStatus) bool {
+	idx := len(*out)
+	*out = append(*out, PathStatus{Path: dir, Dir: true})
+
+	node, err := c.cachedNode(cid)
+	if err != nil {
+		return false
+	}
+	links, err := c.directoryLinksWith(node, c.cachedNode)
+	complete := err == nil
+	for _, l := range links {
+		p := path.Join(dir, l.Name)
+		child, err := c.cachedNode(l.Cid)
+		if err != nil {
+			*out = append(*out, PathStatus{Path: p})
+			complete = false
+			continue
+		}
+		if child.isDir() {
+			complete = c.dirAvailability(l.Cid, p, out) && complete
+			continue
+		}
+		fileComplete := c.fileCached(child)
+		*out = append(*out, PathStatus{Path: p, Complete: fileComplete})
+		complete = complete && fileComplete
+	}
+	(*out)[idx].Complete = complete
+	return complete
+}
+
+// fileCached reports whether every block of a file below node is cached
+func (c *storachaClient) fileCached(node *unixfsNode) bool {
+	for _, l := range node.Links {
+		if multicodec.Code(l.Cid.Prefix().Codec) == multicodec.Raw {
+			if !c.disk.Has(l.Cid) {
+				return false
+			}
+			continue
+		}
+		child, err := c.cachedNode(l.Cid)
+		if err != nil || !c.fileCached(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedNode decodes a block from the local cache without touching the
+// network, and without marking it used, so checking availability does not
+// reorder the cache's eviction
+func (c *storachaClient) cachedNode(cid ipfscid.Cid) (*unixfsNode, error) {
+	if c.disk == nil {
+		return nil, ErrNotCached
+	}
+	raw, ok := c.disk.Peek(cid)
+	if !ok {
+		return nil, ErrNotCached
+	}
+	return decodeNode(cid, raw)
+}
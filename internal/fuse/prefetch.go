@@ -0,0 +1,162 @@
+package fuse
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+)
+
+// PrefetchProgress is a running tally of a prefetch walk
+type PrefetchProgress struct {
+	Blocks       int    // blocks visited so far
+	Fetched      int    // blocks downloaded by this run
+	Cached       int    // blocks that were already in the cache
+	FetchedBytes uint64 // bytes downloaded by this run
+}
+
+// Resolve returns the CID of the entry at p (slash separated) below root
+func (c *storachaClient) Resolve(root, p string) (string, error) {
+	cur, err := ipfscid.Decode(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid CID %q: %w", root, err)
+	}
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		node, err := c.getNode(cur)
+		if err != nil {
+			return "", err
+		}
+		if !node.isDir() {
+			return "", fmt.Errorf("%s is not a directory", cur)
+		}
+		links, err := c.directoryLinks(node)
+		if err != nil {
+			return "", err
+		}
+		found := false
+		for _, l := range links {
+			if l.Name == name {
+				cur, found = l.Cid, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("no entry %q in %s", name, p)
+		}
+	}
+	return cur.String(), nil
+}
+
+// Prefetch downloads every block of the DAG below root into the disk cache
+// using up to concurrency parallel fetches. Blocks already cached are not
+// fetched again, so an interrupted prefetch resumes where it stopped.
+func (c *storachaClient) Prefetch(root string, concurrency int, progress func(PrefetchProgress)) error {
+	if c.disk == nil {
+		return fmt.Errorf("prefetch needs the block cache to be enabled")
+	}
+	if c.offline {
+		return fmt.Errorf("prefetch is not possible in offline mode")
+	}
+	rootCID, err := ipfscid.Decode(root)
+	if err != nil {
+		return fmt.Errorf("invalid CID %q: %w", root, err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// a fixed pool of workers shares a stack of blocks to visit; taking
+	// the most recently found block walks depth first, so the stack stays
+	// about as long as the links of the nodes on one path
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		stack    = []ipfscid.Cid{rootCID}
+		active   int // blocks being fetched, whose links are not stacked yet
+		prog     PrefetchProgress
+		firstErr error
+	)
+
+	worker := func() {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			for len(stack) == 0 && active > 0 && firstErr == nil {
+				cond.Wait()
+			}
+			if len(stack) == 0 || firstErr != nil {
+				// the walk is done or failed; wake the others to see it
+				cond.Broadcast()
+				return
+			}
+			cid := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			active++
+
+			mu.Unlock()
+			links, size, fetched, err := c.prefetchBlock(cid)
+			mu.Lock()
+
+			active--
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			prog.Blocks++
+			if fetched {
+				prog.Fetched++
+				prog.FetchedBytes += uint64(size)
+			} else {
+				prog.Cached++
+			}
+			if progress != nil {
+				progress(prog)
+			}
+			stack = append(stack, links...)
+			cond.Broadcast()
+		}
+	}
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// prefetchBlock makes sure one block is cached and returns the CIDs it links to
+func (c *storachaClient) prefetchBlock(cid ipfscid.Cid) (links []ipfscid.Cid, size int, fetched bool, err error) {
+	raw := multicodec.Code(cid.Prefix().Codec) == multicodec.Raw
+	cached := c.disk.Has(cid)
+	if raw && cached {
+		// raw leaves have no links, no need to read them back
+		return nil, 0, false, nil
+	}
+
+	data, err := c.getBlock(cid)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if raw {
+		return nil, len(data), !cached, nil
+	}
+
+	node, err := decodeNode(cid, data)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	for _, l := range node.Links {
+		links = append(links, l.Cid)
+	}
+	return links, len(data), !cached, nil
+}
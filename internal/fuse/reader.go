@@ -0,0 +1,92 @@
+package fuse
+
+import (
+	"fmt"
+	"io"
+
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// FileReader serves arbitrary byte ranges of a UnixFS file, fetching only the
+// blocks that cover the requested range
+type FileReader interface {
+	io.ReaderAt
+	Size() uint64
+}
+
+// dagReader walks a UnixFS file DAG from its root node down to the leaves
+// that overlap each read
+type dagReader struct {
+	c    *storachaClient
+	cid  ipfscid.Cid
+	root *unixfsNode
+}
+
+func (r *dagReader) Size() uint64 {
+	return r.root.FileSize
+}
+
+func (r *dagReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	size := int64(r.root.FileSize)
+	if off >= size {
+		return 0, io.EOF
+	}
+	want := p
+	if int64(len(want)) > size-off {
+		want = want[:size-off]
+	}
+
+	n, err := r.readNode(r.root, want, uint64(off))
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readNode fills p with bytes of node starting at off (relative to node),
+// descending only into children whose byte range overlaps the request
+func (r *dagReader) readNode(node *unixfsNode, p []byte, off uint64) (int, error) {
+	read := 0
+
+	// a node's inline data precedes the data of its children
+	if dataLen := uint64(len(node.Data)); off < dataLen {
+		read += copy(p, node.Data[off:])
+	}
+	if read == len(p) || len(node.Links) == 0 {
+		return read, nil
+	}
+	if len(node.BlockSizes) != len(node.Links) {
+		return read, fmt.Errorf("file node has %d links but %d block sizes", len(node.Links), len(node.BlockSizes))
+	}
+
+	start := uint64(len(node.Data))
+	for i, l := range node.Links {
+		end := start + node.BlockSizes[i]
+		pos := off + uint64(read)
+		if pos >= end {
+			start = end
+			continue
+		}
+
+		child, err := r.c.getNode(l.Cid)
+		if err != nil {
+			return read, err
+		}
+		n, err := r.readNode(child, p[read:], pos-start)
+		read += n
+		if err != nil {
+			return read, err
+		}
+		if read == len(p) {
+			break
+		}
+		start = end
+	}
+	return read, nil
+}
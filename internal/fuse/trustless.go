@@ -0,0 +1,88 @@
+package fuse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	ipfscid "github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+)
+
+// ErrBlockVerification is returned when a gateway serves bytes that do not
+// hash to the CID they were requested for
+var ErrBlockVerification = errors.New("block failed hash verification")
+
+// fetchBlockCAR retrieves a single block as a CAR (application/vnd.ipld.car)
+// and verifies it against its CID before returning it, so an untrusted
+// gateway can at worst fail a read, never alter it
+func (c *storachaClient) fetchBlockCAR(cid ipfscid.Cid) ([]byte, error) {
+	data, err := c.gateways.fetch("/ipfs/"+cid.String()+"?format=car&dag-scope=block", "application/vnd.ipld.car; version=1", func(body []byte) ([]byte, error) {
+		return extractVerifiedBlock(cid, body)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch block %s: %w", cid, err)
+	}
+	return data, nil
+}
+
+// extractVerifiedBlock verifies every block in a CAR response and returns
+// the one that was asked for
+func extractVerifiedBlock(cid ipfscid.Cid, body []byte) ([]byte, error) {
+	// hashes are checked below so a mismatch surfaces as ErrBlockVerification
+	br, err := carv2.NewBlockReader(bytes.NewReader(body), carv2.WithTrustedCAR(true))
+	if err != nil {
+		return nil, fmt.Errorf("read CAR for %s: %w", cid, err)
+	}
+	// a block-scoped response is rooted at the block that was asked for
+	if !hasRoot(br.Roots, cid) {
+		return nil, fmt.Errorf("CAR for %s has roots %v", cid, br.Roots)
+	}
+
+	var data []byte
+	found := false
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CAR for %s: %w", cid, err)
+		}
+		if err := verifyBlock(blk.Cid(), blk.RawData()); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(blk.Cid().Hash(), cid.Hash()) {
+			data, found = blk.RawData(), true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("CAR for %s did not contain the requested block", cid)
+	}
+	return data, nil
+}
+
+// hasRoot reports whether roots name the block cid; a CIDv0 request may be
+// answered with the CIDv1 of the same block
+func hasRoot(roots []ipfscid.Cid, cid ipfscid.Cid) bool {
+	for _, r := range roots {
+		if bytes.Equal(r.Hash(), cid.Hash()) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyBlock rehashes data with the CID's own hash function and compares
+// the resulting CID with the expected one
+func verifyBlock(cid ipfscid.Cid, data []byte) error {
+	got, err := cid.Prefix().Sum(data)
+	if err != nil {
+		return fmt.Errorf("hash block %s: %w", cid, err)
+	}
+	if !got.Equals(cid) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrBlockVerification, cid, got)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	ipfscid "github.com/ipfs/go-cid"
+	car "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/multiformats/go-multihash"
+)
+
+func rawCID(t *testing.T, data string) ipfscid.Cid {
+	t.Helper()
+	c, err := ipfscid.V1Builder{Codec: ipfscid.Raw, MhType: multihash.SHA2_256}.Sum([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// carBlock is one section of a hand-built CAR; data need not hash to cid
+type carBlock struct {
+	cid  ipfscid.Cid
+	data string
+}
+
+func buildCAR(t *testing.T, roots []ipfscid.Cid, blks ...carBlock) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := car.WriteHeader(&car.CarHeader{Roots: roots, Version: 1}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blks {
+		if err := carutil.LdWrite(&buf, b.cid.Bytes(), []byte(b.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestExtractVerifiedBlock(t *testing.T) {
+	hello, other := rawCID(t, "hello"), rawCID(t, "other")
+	helloV0 := ipfscid.NewCidV0(hello.Hash())
+
+	tests := []struct {
+		name       string
+		want       ipfscid.Cid
+		car        []byte
+		verifyFail bool // the error must be ErrBlockVerification
+		wantErr    bool
+	}{
+		{
+			name: "good",
+			want: hello,
+			car:  buildCAR(t, []ipfscid.Cid{hello}, carBlock{hello, "hello"}),
+		},
+		{
+			name: "CIDv0 request answered with CIDv1",
+			want: helloV0,
+			car:  buildCAR(t, []ipfscid.Cid{hello}, carBlock{hello, "hello"}),
+		},
+		{
+			name:       "tampered block",
+			want:       hello,
+			car:        buildCAR(t, []ipfscid.Cid{hello}, carBlock{hello, "HELLO"}),
+			verifyFail: true,
+			wantErr:    true,
+		},
+		{
+			name:       "tampered extra block",
+			want:       hello,
+			car:        buildCAR(t, []ipfscid.Cid{hello}, carBlock{hello, "hello"}, carBlock{other, "tampered"}),
+			verifyFail: true,
+			wantErr:    true,
+		},
+		{
+			name:    "requested block missing",
+			want:    hello,
+			car:     buildCAR(t, []ipfscid.Cid{hello}, carBlock{other, "other"}),
+			wantErr: true,
+		},
+		{
+			name:    "root does not match",
+			want:    hello,
+			car:     buildCAR(t, []ipfscid.Cid{other}, carBlock{hello, "hello"}),
+			wantErr: true,
+		},
+		{
+			name:    "not a CAR",
+			want:    hello,
+			car:     []byte("hello"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := extractVerifiedBlock(tt.want, tt.car)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != "hello" {
+					t.Errorf("block = %q, want %q", data, "hello")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("returned %q, want an error", data)
+			}
+			if got := errors.Is(err, ErrBlockVerification); got != tt.verifyFail {
+				t.Errorf("error %v: is ErrBlockVerification = %v, want %v", err, got, tt.verifyFail)
+			}
+		})
+	}
+}
+
+// failingReader fails every read with err
+type failingReader struct{ err error }
+
+func (r failingReader) ReadAt([]byte, int64) (int, error) { return 0, r.err }
+func (r failingReader) Size() uint64                      { return 1 }
+
+func TestReadVerificationFailureIsEIO(t *testing.T) {
+	h := &fileHandle{r: failingReader{fmt.Errorf("fetch block: %w", ErrBlockVerification)}, file: &StorachaFile{}}
+	if _, errno := h.Read(context.Background(), make([]byte, 1), 0); errno != syscall.EIO {
+		t.Errorf("Read = %v, want EIO", errno)
+	}
+}
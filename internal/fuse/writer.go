@@ -0,0 +1,334 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/hanwen/go-fuse/v2/fs"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// Publisher persists the blocks produce emits for a write and records the
+// root it returns as the new root of the mounted tree. Blocks are handed on
+// as they are produced, so a large file is never held in memory whole.
+type Publisher interface {
+	Publish(ctx context.Context, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) error
+}
+
+// mountWriter serialises changes to the mounted tree. Each change re-encodes
+// the directories from the changed one up to the root and publishes the new
+// blocks before any node is updated, so a failed upload leaves the mount as it was.
+type mountWriter struct {
+	client     *storachaClient
+	publisher  Publisher
+	stagingDir string
+	params     unixfs.Params
+	debug      bool
+
+	mu      sync.Mutex
+	root    ipfscid.Cid
+	pending []blocks.Block // blocks linked from the tree but not yet published
+}
+
+// dirUpdate is the new state of one directory on the path to the root
+type dirUpdate struct {
+	dir     *StorachaDir
+	cid     ipfscid.Cid
+	entries []FileEntry
+}
+
+// dirEdit rewrites the entries of one directory
+type dirEdit func([]FileEntry) ([]FileEntry, error)
+
+// commit publishes the blocks emit produces, then applies each edit to its
+// directory and rebuilds every ancestor up to the root once. Edits run after
+// emit, so they may link what it imported. Callers hold w.mu.
+func (w *mountWriter) commit(ctx context.Context, edits map[*StorachaDir]dirEdit, emit func(unixfs.BlockSink) error) error {
+	var (
+		updates []dirUpdate
+		root    ipfscid.Cid
+	)
+	err := w.publisher.Publish(ctx, func(sink unixfs.BlockSink) (ipfscid.Cid, error) {
+		for _, b := range w.pending {
+			if err := sink(b); err != nil {
+				return ipfscid.Undef, err
+			}
+		}
+		// written blocks are readable through the mount before the upload ends
+		keep := func(b blocks.Block) error {
+			w.client.keep(b)
+			return sink(b)
+		}
+		if emit != nil {
+			if err := emit(keep); err != nil {
+				return ipfscid.Undef, err
+			}
+		}
+		var err error
+		updates, err = w.rebuild(edits, keep)
+		if err != nil {
+			return ipfscid.Undef, err
+		}
+		root = updates[len(updates)-1].cid
+		return root, nil
+	})
+	if err != nil {
+		if root.Defined() {
+			return fmt.Errorf("publish %s: %w", root, err)
+		}
+		return err
+	}
+	w.pending = nil
+	w.root = root
+
+	for _, u := range updates {
+		u.dir.mu.Lock()
+		u.dir.cid = u.cid.String()
+		u.dir.entries = u.entries
+		u.dir.mu.Unlock()
+	}
+	log.Printf("Published new root CID: %s", root)
+	return nil
+}
+
+// rebuild applies edits and re-encodes the edited directories and their
+// ancestors into sink, returning their new states with the root last
+func (w *mountWriter) rebuild(edits map[*StorachaDir]dirEdit, sink unixfs.BlockSink) ([]dirUpdate, error) {
+	// the edited directories and their ancestors, deepest first so that
+	// subdirectories are rebuilt before the parents linking to them
+	depth := make(map[*StorachaDir]int)
+	for d := range edits {
+		for a := d; a != nil; a = parentDir(a) {
+			if _, seen := depth[a]; seen {
+				break
+			}
+			depth[a] = dirDepth(a)
+		}
+	}
+	order := make([]*StorachaDir, 0, len(depth))
+	for d := range depth {
+		order = append(order, d)
+	}
+	sort.Slice(order, func(i, j int) bool { return depth[order[i]] > depth[order[j]] })
+
+	rebuilt := make(map[*StorachaDir][]FileEntry) // new links to subdirectories, by parent
+	var updates []dirUpdate
+	for _, d := range order {
+		current, errno := d.list()
+		if errno != 0 {
+			return nil, fmt.Errorf("list %q: %s", "/"+d.dir(), errno)
+		}
+		entries := append([]FileEntry(nil), current...)
+		if edit, ok := edits[d]; ok {
+			var err error
+			if entries, err = edit(entries); err != nil {
+				return nil, err
+			}
+		}
+		for _, sub := range rebuilt[d] {
+			entries = replaceEntry(entries, sub.Name, sub)
+		}
+
+		links, err := dirEntries(entries)
+		if err != nil {
+			return nil, err
+		}
+		link, err := unixfs.BuildDirectory(links, w.params, sink)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, dirUpdate{dir: d, cid: link.Cid, entries: entries})
+
+		if name, parent := d.Parent(); parent != nil {
+			p := asDir(parent)
+			rebuilt[p] = append(rebuilt[p], FileEntry{Name: name, Dir: true, CID: link.Cid.String(), Tsize: link.Tsize})
+		}
+	}
+	return updates, nil
+}
+
+// publishEdits commits edits for a directory operation described by op and
+// maps a failure to EIO. Callers hold w.mu.
+func (w *mountWriter) publishEdits(ctx context.Context, op string, edits map[*StorachaDir]dirEdit, emit func(unixfs.BlockSink) error) syscall.Errno {
+	if err := w.commit(ctx, edits, emit); err != nil {
+		log.Printf("Failed to %s: %v", op, err)
+		return syscall.EIO
+	}
+	if w.debug {
+		log.Printf("Committed %s", op)
+	}
+	return 0
+}
+
+// commitFile imports the staged content of f, streaming its blocks to the
+// publisher, and links it into its directory
+func (w *mountWriter) commitFile(ctx context.Context, f *StorachaFile, staged io.ReaderAt, size int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	name, parent := f.Parent()
+	dir := asDir(parent)
+	if dir == nil {
+		// unlinked while open, like a deleted local file its writes go nowhere
+		log.Println("Not uploading writes to a file removed while open")
+		return nil
+	}
+
+	var entry FileEntry
+	err := w.commit(ctx, map[*StorachaDir]dirEdit{dir: func(entries []FileEntry) ([]FileEntry, error) {
+		return replaceEntry(entries, name, entry), nil
+	}}, func(sink unixfs.BlockSink) error {
+		link, err := unixfs.ImportFile(io.NewSectionReader(staged, 0, size), w.params, sink)
+		if err != nil {
+			return err
+		}
+		entry = FileEntry{Name: name, Size: link.Size, CID: link.Cid.String(), Tsize: link.Tsize}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.cid, f.size = entry.CID, entry.Size
+	f.mu.Unlock()
+	if w.debug {
+		log.Printf("Committed %s as %s (%d bytes)", f.path(), entry.CID, entry.Size)
+	}
+	return nil
+}
+
+// addEmptyFile links a new empty file into dir without publishing; its block
+// goes out with the next commit, normally the flush of the file itself.
+// Callers hold w.mu.
+func (w *mountWriter) addEmptyFile(dir *StorachaDir, name string) (FileEntry, error) {
+	link, err := unixfs.ImportFile(strings.NewReader(""), w.params, func(b blocks.Block) error {
+		w.client.keep(b)
+		w.pending = append(w.pending, b)
+		return nil
+	})
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	entry := FileEntry{Name: name, CID: link.Cid.String(), Tsize: link.Tsize}
+	dir.mu.Lock()
+	dir.entries = replaceEntry(append([]FileEntry(nil), dir.entries...), name, entry)
+	dir.mu.Unlock()
+	return entry, nil
+}
+
+// stage creates a staging file for f, seeded with its current content unless truncate is set
+func (w *mountWriter) stage(f *StorachaFile, truncate bool) (*os.File, error) {
+	tmp, err := os.CreateTemp(w.stagingDir, "write-*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging file: %w", err)
+	}
+	f.mu.Lock()
+	cid, size := f.cid, f.size
+	f.mu.Unlock()
+	if truncate || size == 0 {
+		return tmp, nil
+	}
+
+	r, err := f.client.OpenFile(cid)
+	if err == nil {
+		_, err = io.Copy(tmp, io.NewSectionReader(r, 0, int64(r.Size())))
+	}
+	if err != nil {
+		discardStaging(tmp)
+		return nil, fmt.Errorf("stage %s: %w", f.path(), err)
+	}
+	return tmp, nil
+}
+
+func discardStaging(tmp *os.File) {
+	_ = tmp.Close()
+	if err := os.Remove(tmp.Name()); err != nil {
+		log.Printf("Failed to remove staging file %s: %v", tmp.Name(), err)
+	}
+}
+
+// perm is the permission bits of nodes on the mount; nil means read-only
+func (w *mountWriter) perm(dir bool) uint32 {
+	switch {
+	case w == nil && dir:
+		return 0555
+	case w == nil:
+		return 0444
+	case dir:
+		return 0755
+	}
+	return 0644
+}
+
+// asDir returns the directory node behind an inode, or nil above the root
+func asDir(in *fs.Inode) *StorachaDir {
+	if in == nil {
+		return nil
+	}
+	switch n := in.Operations().(type) {
+	case *StorachaDir:
+		return n
+	case *StorachaFS:
+		return &n.StorachaDir
+	}
+	return nil
+}
+
+func parentDir(d *StorachaDir) *StorachaDir {
+	_, parent := d.Parent()
+	return asDir(parent)
+}
+
+// dirDepth is the number of directories between d and the root
+func dirDepth(d *StorachaDir) int {
+	n := 0
+	for p := parentDir(d); p != nil; p = parentDir(p) {
+		n++
+	}
+	return n
+}
+
+// replaceEntry returns entries with the one called name replaced by e, or e appended
+func replaceEntry(entries []FileEntry, name string, e FileEntry) []FileEntry {
+	for i := range entries {
+		if entries[i].Name == name {
+			entries[i] = e
+			return entries
+		}
+	}
+	return append(entries, e)
+}
+
+// removeEntry returns entries without the one called name
+func removeEntry(entries []FileEntry, name string) []FileEntry {
+	out := entries[:0]
+	for _, e := range entries {
+		if e.Name != name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func dirEntries(entries []FileEntry) ([]unixfs.DirEntry, error) {
+	out := make([]unixfs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		c, err := ipfscid.Decode(e.CID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CID %q for entry %q: %w", e.CID, e.Name, err)
+		}
+		out = append(out, unixfs.DirEntry{Name: e.Name, Link: unixfs.Link{Cid: c, Size: e.Size, Tsize: e.Tsize}})
+	}
+	return out, nil
+}
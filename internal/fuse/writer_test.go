@@ -0,0 +1,562 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// fakePublisher records the blocks and root of every publish, or fails them
+// all with fail after producing the blocks
+type fakePublisher struct {
+	fail      error
+	roots     []ipfscid.Cid
+	published [][]blocks.Block
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) error {
+	var emitted []blocks.Block
+	root, err := produce(func(b blocks.Block) error {
+		emitted = append(emitted, b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if p.fail != nil {
+		return p.fail
+	}
+	p.roots = append(p.roots, root)
+	p.published = append(p.published, emitted)
+	return nil
+}
+
+// testMount is a writable StorachaFS driven through the go-fuse bridge, as
+// the kernel would drive it, over the tree
+//
+//	a.txt
+//	sub/b.txt
+//	sub/inner/
+//	other/
+//
+// whose blocks the client holds, so nothing is fetched
+type testMount struct {
+	t   *testing.T
+	pub *fakePublisher
+	fs  *StorachaFS
+	raw fuse.RawFileSystem
+}
+
+func newTestMount(t *testing.T, stagingDir string) *testMount {
+	t.Helper()
+	pub := &fakePublisher{}
+	params := unixfs.DefaultParams()
+	root := NewStorachaFS("", &ClientConfig{Offline: true, Publisher: pub, StagingDir: stagingDir, Import: params})
+	c := root.client.(*storachaClient)
+	keep := func(b blocks.Block) error {
+		c.keep(b)
+		return nil
+	}
+
+	file := func(content string) unixfs.Link {
+		link, err := unixfs.ImportFile(strings.NewReader(content), params, keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link
+	}
+	dir := func(entries ...unixfs.DirEntry) unixfs.Link {
+		link, err := unixfs.BuildDirectory(entries, params, keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link
+	}
+	rootLink := dir(
+		unixfs.DirEntry{Name: "a.txt", Link: file("alpha")},
+		unixfs.DirEntry{Name: "sub", Link: dir(
+			unixfs.DirEntry{Name: "b.txt", Link: file("bravo")},
+			unixfs.DirEntry{Name: "inner", Link: dir()},
+		)},
+		unixfs.DirEntry{Name: "other", Link: dir()},
+	)
+	root.cid = rootLink.Cid.String()
+	root.w.root = rootLink.Cid
+
+	return &testMount{t: t, pub: pub, fs: root, raw: fs.NewNodeFS(root, &fs.Options{})}
+}
+
+// lookup returns the node ID of the entry at the slash separated path p
+func (m *testMount) lookup(p string) uint64 {
+	m.t.Helper()
+	id := uint64(fuse.FUSE_ROOT_ID)
+	for _, name := range strings.Split(p, "/") {
+		var out fuse.EntryOut
+		if st := m.raw.Lookup(nil, &fuse.InHeader{NodeId: id}, name, &out); st != fuse.OK {
+			m.t.Fatalf("lookup %s: %v", p, st)
+		}
+		id = out.NodeId
+	}
+	return id
+}
+
+// dir returns the directory node at p, "" being the root
+func (m *testMount) dir(p string) *StorachaDir {
+	m.t.Helper()
+	in := m.fs.EmbeddedInode()
+	if p != "" {
+		m.lookup(p)
+		for _, name := range strings.Split(p, "/") {
+			in = in.GetChild(name)
+		}
+	}
+	d := asDir(in)
+	if d == nil {
+		m.t.Fatalf("%s is not a directory", p)
+	}
+	return d
+}
+
+// create creates the file name in the directory at p and returns its node ID
+// and handle
+func (m *testMount) create(p, name string) (uint64, uint64, fuse.Status) {
+	var out fuse.CreateOut
+	st := m.raw.Create(nil, &fuse.CreateIn{InHeader: fuse.InHeader{NodeId: m.lookup(p)}, Flags: syscall.O_WRONLY, Mode: 0644}, name, &out)
+	return out.NodeId, out.Fh, st
+}
+
+// open opens the file at p with flags and returns its node ID and handle
+func (m *testMount) open(p string, flags uint32) (uint64, uint64) {
+	m.t.Helper()
+	id := m.lookup(p)
+	var out fuse.OpenOut
+	if st := m.raw.Open(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: id}, Flags: flags}, &out); st != fuse.OK {
+		m.t.Fatalf("open %s: %v", p, st)
+	}
+	return id, out.Fh
+}
+
+// writeAndClose writes data to an open file and closes it, returning the
+// status of the flush that commits it
+func (m *testMount) writeAndClose(id, fh uint64, data string) fuse.Status {
+	m.t.Helper()
+	if _, st := m.raw.Write(nil, &fuse.WriteIn{InHeader: fuse.InHeader{NodeId: id}, Fh: fh}, []byte(data)); st != fuse.OK {
+		m.t.Fatalf("write: %v", st)
+	}
+	st := m.raw.Flush(nil, &fuse.FlushIn{InHeader: fuse.InHeader{NodeId: id}, Fh: fh})
+	m.raw.Release(nil, &fuse.ReleaseIn{InHeader: fuse.InHeader{NodeId: id}, Fh: fh})
+	return st
+}
+
+// entries lists the directory at p in the published tree under root
+func (m *testMount) entries(root ipfscid.Cid, p string) map[string]FileEntry {
+	m.t.Helper()
+	c := m.fs.client
+	dirCID, err := c.Resolve(root.String(), p)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	list, err := c.ListDir(dirCID)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	out := make(map[string]FileEntry, len(list))
+	for _, e := range list {
+		out[e.Name] = e
+	}
+	return out
+}
+
+// read returns the content of the file at p in the published tree under root
+func (m *testMount) read(root ipfscid.Cid, p string) string {
+	m.t.Helper()
+	c := m.fs.client
+	fileCID, err := c.Resolve(root.String(), p)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	r, err := c.OpenFile(fileCID)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, int64(r.Size())))
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return string(data)
+}
+
+// lastRoot is the root of the latest publish
+func (m *testMount) lastRoot() ipfscid.Cid {
+	m.t.Helper()
+	if len(m.pub.roots) == 0 {
+		m.t.Fatal("nothing was published")
+	}
+	return m.pub.roots[len(m.pub.roots)-1]
+}
+
+func TestWriteNestedFile(t *testing.T) {
+	m := newTestMount(t, t.TempDir())
+	id, fh, st := m.create("sub/inner", "c.txt")
+	if st != fuse.OK {
+		t.Fatalf("create: %v", st)
+	}
+	if st := m.writeAndClose(id, fh, "charlie"); st != fuse.OK {
+		t.Fatalf("flush: %v", st)
+	}
+
+	if len(m.pub.roots) != 1 {
+		t.Fatalf("published %d roots, want 1", len(m.pub.roots))
+	}
+	root := m.lastRoot()
+	if got := m.read(root, "sub/inner/c.txt"); got != "charlie" {
+		t.Errorf("sub/inner/c.txt = %q, want %q", got, "charlie")
+	}
+	if got := m.read(root, "sub/b.txt"); got != "bravo" {
+		t.Errorf("sub/b.txt = %q, want %q", got, "bravo")
+	}
+	if got := m.read(root, "a.txt"); got != "alpha" {
+		t.Errorf("a.txt = %q, want %q", got, "alpha")
+	}
+	if m.fs.cid != root.String() || !m.fs.w.root.Equals(root) {
+		t.Errorf("mount root is %s, want the published %s", m.fs.cid, root)
+	}
+
+	// every node on the path to the new file went out with the publish
+	published := make(map[string]bool)
+	for _, b := range m.pub.published[0] {
+		published[b.Cid().KeyString()] = true
+	}
+	for _, p := range []string{"", "sub", "sub/inner", "sub/inner/c.txt"} {
+		c, err := m.fs.client.Resolve(root.String(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !published[ipfscid.MustParse(c).KeyString()] {
+			t.Errorf("block of /%s was not published", p)
+		}
+	}
+}
+
+func TestFailedPublishLeavesTreeUnchanged(t *testing.T) {
+	type dirState struct {
+		cid     string
+		entries []FileEntry
+	}
+	dirs := []string{"", "sub", "sub/inner", "other"}
+	snapshot := func(m *testMount) map[string]dirState {
+		out := make(map[string]dirState)
+		for _, p := range dirs {
+			d := m.dir(p)
+			if _, errno := d.list(); errno != 0 {
+				t.Fatalf("list /%s: %v", p, errno)
+			}
+			d.mu.Lock()
+			out[p] = dirState{cid: d.cid, entries: append([]FileEntry(nil), d.entries...)}
+			d.mu.Unlock()
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		op   func(m *testMount) fuse.Status
+	}{
+		{"write", func(m *testMount) fuse.Status {
+			id, fh := m.open("sub/b.txt", syscall.O_WRONLY|syscall.O_TRUNC)
+			return m.writeAndClose(id, fh, "changed")
+		}},
+		{"mkdir", func(m *testMount) fuse.Status {
+			var out fuse.EntryOut
+			return m.raw.Mkdir(nil, &fuse.MkdirIn{InHeader: fuse.InHeader{NodeId: m.lookup("sub/inner")}, Mode: 0755}, "new", &out)
+		}},
+		{"unlink", func(m *testMount) fuse.Status {
+			return m.raw.Unlink(nil, &fuse.InHeader{NodeId: m.lookup("sub")}, "b.txt")
+		}},
+		{"rename", func(m *testMount) fuse.Status {
+			in := &fuse.RenameIn{InHeader: fuse.InHeader{NodeId: m.lookup("sub")}, Newdir: m.lookup("other")}
+			return m.raw.Rename(nil, in, "b.txt", "b.txt")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMount(t, t.TempDir())
+			before, root := snapshot(m), m.fs.w.root
+			m.pub.fail = errors.New("service unavailable")
+
+			if st := tt.op(m); st != fuse.EIO {
+				t.Fatalf("%s returned %v, want EIO", tt.name, st)
+			}
+			if after := snapshot(m); !reflect.DeepEqual(after, before) {
+				t.Errorf("directories changed by a failed publish:\nbefore %+v\nafter  %+v", before, after)
+			}
+			if !m.fs.w.root.Equals(root) {
+				t.Errorf("root moved to %s after a failed publish", m.fs.w.root)
+			}
+		})
+	}
+}
+
+func TestFileUnlinkedWhileOpenIsNotCommitted(t *testing.T) {
+	m := newTestMount(t, t.TempDir())
+	id, fh := m.open("sub/b.txt", syscall.O_WRONLY)
+	if st := m.raw.Unlink(nil, &fuse.InHeader{NodeId: m.lookup("sub")}, "b.txt"); st != fuse.OK {
+		t.Fatalf("unlink: %v", st)
+	}
+	unlinked := m.lastRoot()
+
+	if st := m.writeAndClose(id, fh, "too late"); st != fuse.OK {
+		t.Fatalf("flush: %v", st)
+	}
+	if len(m.pub.roots) != 1 {
+		t.Errorf("published %d roots, want only the unlink", len(m.pub.roots))
+	}
+	if m.fs.cid != unlinked.String() {
+		t.Errorf("mount root is %s, want %s", m.fs.cid, unlinked)
+	}
+	if _, ok := m.entries(m.fs.w.root, "sub")["b.txt"]; ok {
+		t.Error("b.txt is linked again")
+	}
+}
+
+func TestCreateStagingFailureLinksNothing(t *testing.T) {
+	m := newTestMount(t, filepath.Join(t.TempDir(), "missing"))
+	if _, _, st := m.create("sub/inner", "c.txt"); st != fuse.EIO {
+		t.Fatalf("create returned %v, want EIO", st)
+	}
+	var out fuse.EntryOut
+	if st := m.raw.Lookup(nil, &fuse.InHeader{NodeId: m.lookup("sub/inner")}, "c.txt", &out); st != fuse.ENOENT {
+		t.Errorf("lookup after a failed create returned %v, want ENOENT", st)
+	}
+	if len(m.fs.w.pending) != 0 {
+		t.Errorf("%d blocks pending after a failed create", len(m.fs.w.pending))
+	}
+}
+
+// publishedDirs returns the CIDs of the directory blocks publish n emitted
+func (m *testMount) publishedDirs(n int) []ipfscid.Cid {
+	m.t.Helper()
+	var dirs []ipfscid.Cid
+	for _, b := range m.pub.published[n] {
+		node, err := decodeNode(b.Cid(), b.RawData())
+		if err != nil {
+			continue // raw leaves are not dag-pb
+		}
+		if node.isDir() {
+			dirs = append(dirs, b.Cid())
+		}
+	}
+	return dirs
+}
+
+func TestDirectoryOps(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(m *testMount) fuse.Status
+		want  fuse.Status
+		check func(t *testing.T, m *testMount, root ipfscid.Cid)
+	}{
+		{
+			name: "mkdir",
+			op: func(m *testMount) fuse.Status {
+				var out fuse.EntryOut
+				return m.raw.Mkdir(nil, &fuse.MkdirIn{InHeader: fuse.InHeader{NodeId: m.lookup("sub")}, Mode: 0755}, "new", &out)
+			},
+			want: fuse.OK,
+			check: func(t *testing.T, m *testMount, root ipfscid.Cid) {
+				e, ok := m.entries(root, "sub")["new"]
+				if !ok || !e.Dir {
+					t.Fatalf("sub/new is %+v, want a directory", e)
+				}
+				if n := len(m.entries(root, "sub/new")); n != 0 {
+					t.Errorf("new directory has %d entries", n)
+				}
+			},
+		},
+		{
+			name: "mkdir existing",
+			op: func(m *testMount) fuse.Status {
+				var out fuse.EntryOut
+				return m.raw.Mkdir(nil, &fuse.MkdirIn{InHeader: fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, Mode: 0755}, "a.txt", &out)
+			},
+			want: fuse.Status(syscall.EEXIST),
+		},
+		{
+			name: "unlink",
+			op: func(m *testMount) fuse.Status {
+				return m.raw.Unlink(nil, &fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, "a.txt")
+			},
+			want: fuse.OK,
+			check: func(t *testing.T, m *testMount, root ipfscid.Cid) {
+				entries := m.entries(root, "")
+				if _, ok := entries["a.txt"]; ok {
+					t.Error("a.txt still linked")
+				}
+				if _, ok := entries["sub"]; !ok {
+					t.Error("sub lost")
+				}
+			},
+		},
+		{
+			name: "unlink directory",
+			op: func(m *testMount) fuse.Status {
+				return m.raw.Unlink(nil, &fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, "sub")
+			},
+			want: fuse.Status(syscall.EISDIR),
+		},
+		{
+			name: "rmdir",
+			op: func(m *testMount) fuse.Status {
+				return m.raw.Rmdir(nil, &fuse.InHeader{NodeId: m.lookup("sub")}, "inner")
+			},
+			want: fuse.OK,
+			check: func(t *testing.T, m *testMount, root ipfscid.Cid) {
+				entries := m.entries(root, "sub")
+				if _, ok := entries["inner"]; ok {
+					t.Error("sub/inner still linked")
+				}
+				if _, ok := entries["b.txt"]; !ok {
+					t.Error("sub/b.txt lost")
+				}
+			},
+		},
+		{
+			name: "rmdir not empty",
+			op: func(m *testMount) fuse.Status {
+				return m.raw.Rmdir(nil, &fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, "sub")
+			},
+			want: fuse.Status(syscall.ENOTEMPTY),
+		},
+		{
+			name: "rmdir file",
+			op: func(m *testMount) fuse.Status {
+				return m.raw.Rmdir(nil, &fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, "a.txt")
+			},
+			want: fuse.ENOTDIR,
+		},
+		{
+			name: "rename within directory",
+			op: func(m *testMount) fuse.Status {
+				in := &fuse.RenameIn{InHeader: fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, Newdir: fuse.FUSE_ROOT_ID}
+				return m.raw.Rename(nil, in, "a.txt", "z.txt")
+			},
+			want: fuse.OK,
+			check: func(t *testing.T, m *testMount, root ipfscid.Cid) {
+				if _, ok := m.entries(root, "")["a.txt"]; ok {
+					t.Error("a.txt still linked")
+				}
+				if got := m.read(root, "z.txt"); got != "alpha" {
+					t.Errorf("z.txt = %q, want %q", got, "alpha")
+				}
+			},
+		},
+		{
+			name: "rename file over directory",
+			op: func(m *testMount) fuse.Status {
+				in := &fuse.RenameIn{InHeader: fuse.InHeader{NodeId: fuse.FUSE_ROOT_ID}, Newdir: fuse.FUSE_ROOT_ID}
+				return m.raw.Rename(nil, in, "a.txt", "other")
+			},
+			want: fuse.EISDIR,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMount(t, t.TempDir())
+			if st := tt.op(m); st != tt.want {
+				t.Fatalf("returned %v, want %v", st, tt.want)
+			}
+			if tt.want != fuse.OK {
+				if len(m.pub.roots) != 0 {
+					t.Errorf("a failed operation published %d roots", len(m.pub.roots))
+				}
+				return
+			}
+			if len(m.pub.roots) != 1 {
+				t.Fatalf("published %d roots, want 1", len(m.pub.roots))
+			}
+			root := m.lastRoot()
+			if m.fs.cid != root.String() {
+				t.Errorf("mount root is %s, want the published %s", m.fs.cid, root)
+			}
+			tt.check(t, m, root)
+		})
+	}
+}
+
+func TestRenameAcrossDirectories(t *testing.T) {
+	m := newTestMount(t, t.TempDir())
+	src, dst := m.dir("sub"), m.dir("other")
+	moved, err := m.fs.client.Resolve(m.fs.cid, "sub/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.lookup("sub/b.txt") // the inode go-fuse has to move
+
+	in := &fuse.RenameIn{InHeader: fuse.InHeader{NodeId: m.lookup("sub")}, Newdir: m.lookup("other")}
+	if st := m.raw.Rename(nil, in, "b.txt", "moved.txt"); st != fuse.OK {
+		t.Fatalf("rename: %v", st)
+	}
+	if len(m.pub.roots) != 1 {
+		t.Fatalf("published %d roots, want 1", len(m.pub.roots))
+	}
+	root := m.lastRoot()
+
+	// both parents and the root are rebuilt exactly once, in one publish
+	want := map[string]bool{src.cid: true, dst.cid: true, root.String(): true}
+	dirs := m.publishedDirs(0)
+	if len(dirs) != len(want) {
+		t.Errorf("published %d directory blocks %v, want %d", len(dirs), dirs, len(want))
+	}
+	for _, c := range dirs {
+		if !want[c.String()] {
+			t.Errorf("published directory %s is not sub, other or the root", c)
+		}
+	}
+
+	if _, ok := m.entries(root, "sub")["b.txt"]; ok {
+		t.Error("sub/b.txt still linked")
+	}
+	if _, ok := m.entries(root, "sub")["inner"]; !ok {
+		t.Error("sub/inner lost")
+	}
+	if e := m.entries(root, "other")["moved.txt"]; e.CID != moved {
+		t.Errorf("other/moved.txt is %q, want the moved file %s", e.CID, moved)
+	}
+	if _, errno := src.entry("b.txt"); errno != syscall.ENOENT {
+		t.Error("source directory node still lists b.txt")
+	}
+	if got := m.dir("other").GetChild("moved.txt"); got == nil || got.Path(nil) != "other/moved.txt" {
+		t.Errorf("inode of the moved file is not at other/moved.txt")
+	}
+}
+
+func TestDirSetattr(t *testing.T) {
+	m := newTestMount(t, t.TempDir())
+	in := &fuse.SetAttrIn{SetAttrInCommon: fuse.SetAttrInCommon{InHeader: fuse.InHeader{NodeId: m.lookup("sub")}, Valid: fuse.FATTR_MODE, Mode: 0700}}
+	var out fuse.AttrOut
+	if st := m.raw.SetAttr(nil, in, &out); st != fuse.OK {
+		t.Fatalf("setattr: %v", st)
+	}
+	if out.Mode&07777 != 0755 {
+		t.Errorf("mode is %o after setattr, want the mount's 755", out.Mode&07777)
+	}
+	if len(m.pub.roots) != 0 {
+		t.Errorf("setattr published %d roots", len(m.pub.roots))
+	}
+
+	readOnly := &StorachaDir{}
+	if errno := readOnly.Setattr(context.Background(), nil, in, &out); errno != syscall.EROFS {
+		t.Errorf("setattr on a read-only mount returned %v, want EROFS", errno)
+	}
+}
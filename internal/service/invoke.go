@@ -0,0 +1,98 @@
+// Package service invokes Storacha service abilities guppy has no client
+// method for
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	uclient "github.com/storacha/go-ucanto/client"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/receipt"
+	"github.com/storacha/go-ucanto/core/result"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/guppy/pkg/client"
+	"github.com/storacha/guppy/pkg/client/nodevalue"
+)
+
+// Invoke executes the ability can on with, using the proofs of c and extra,
+// and returns the ok result of its receipt
+func Invoke(ctx context.Context, c *client.Client, can, with string, nb Caveats, extra ...delegation.Delegation) (map[string]any, error) {
+	proofs := make([]delegation.Proof, 0, len(c.Proofs())+len(extra))
+	for _, p := range c.Proofs() {
+		proofs = append(proofs, delegation.FromDelegation(p))
+	}
+	for _, p := range extra {
+		proofs = append(proofs, delegation.FromDelegation(p))
+	}
+	inv, err := invocation.Invoke(c.Issuer(), c.Connection().ID(), ucan.NewCapability(can, with, nb), delegation.WithProof(proofs...))
+	if err != nil {
+		return nil, fmt.Errorf("generating `%s` invocation: %w", can, err)
+	}
+	resp, err := uclient.Execute(ctx, []invocation.Invocation{inv}, c.Connection())
+	if err != nil {
+		return nil, fmt.Errorf("sending `%s` invocation: %w", can, err)
+	}
+	rcptLink, ok := resp.Get(inv.Link())
+	if !ok {
+		return nil, fmt.Errorf("receipt not found: %s", inv.Link())
+	}
+	rcpt, err := receipt.NewAnyReceiptReader().Read(rcptLink, resp.Blocks())
+	if err != nil {
+		return nil, fmt.Errorf("reading `%s` receipt: %w", can, err)
+	}
+
+	okNode, errNode := result.Unwrap(rcpt.Out())
+	if errNode != nil {
+		v, err := nodevalue.NodeValue(errNode)
+		if err != nil {
+			return nil, fmt.Errorf("`%s` failed", can)
+		}
+		if m, ok := v.(map[string]any); ok && m["message"] != nil {
+			return nil, fmt.Errorf("`%s` failed: %v", can, m["message"])
+		}
+		return nil, fmt.Errorf("`%s` failed: %v", can, v)
+	}
+	v, err := nodevalue.NodeValue(okNode)
+	if err != nil {
+		return nil, fmt.Errorf("reading `%s` result: %w", can, err)
+	}
+	out, _ := v.(map[string]any)
+	return out, nil
+}
+
+// Caveats builds an invocation's nb from strings, integers, links and
+// nested caveats
+type Caveats map[string]any
+
+func (nb Caveats) ToIPLD() (datamodel.Node, error) {
+	return qp.BuildMap(basicnode.Prototype.Any, int64(len(nb)), nb.assemble)
+}
+
+func (nb Caveats) assemble(ma datamodel.MapAssembler) {
+	keys := make([]string, 0, len(nb))
+	for k := range nb {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch v := nb[k].(type) {
+		case string:
+			qp.MapEntry(ma, k, qp.String(v))
+		case int64:
+			qp.MapEntry(ma, k, qp.Int(v))
+		case cidlink.Link:
+			qp.MapEntry(ma, k, qp.Link(v))
+		case Caveats:
+			qp.MapEntry(ma, k, qp.Map(int64(len(v)), v.assemble))
+		default:
+			panic(fmt.Sprintf("unsupported caveat %s of type %T", k, v))
+		}
+	}
+}
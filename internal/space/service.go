@@ -0,0 +1,124 @@
+package space
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/service"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/principal"
+	"github.com/storacha/go-ucanto/ucan"
+	"github.com/storacha/guppy/pkg/client"
+)
+
+// Provider is the storage provider new spaces are provisioned with
+const Provider = "did:web:web3.storage"
+
+// Create makes key a space named name and delegates every ability in it to
+// the agent of c, which saves the delegation with its other proofs. If
+// account is defined the space is then provisioned with Provision. The
+// caller keeps key: it is the only way to retry a failed Provision.
+func Create(ctx context.Context, c *client.Client, key principal.Signer, name string, account did.DID) (Space, error) {
+	toAgent, err := delegateAll(key, c.Issuer(), name)
+	if err != nil {
+		return Space{}, err
+	}
+	if err := c.AddProofs(toAgent); err != nil {
+		return Space{}, fmt.Errorf("save delegation: %w", err)
+	}
+	sp := Space{DID: key.DID(), Name: name, Abilities: []string{"*"}}
+	if account == (did.DID{}) {
+		return sp, nil
+	}
+	return sp, Provision(ctx, c, key, name, account)
+}
+
+// Provision registers the space of key with account as its storage
+// provider's consumer, and stores a delegation of the space to the account
+// with the service, so that logging in to the account from another agent
+// recovers the space. It can be run again when either step failed.
+func Provision(ctx context.Context, c *client.Client, key principal.Signer, name string, account did.DID) error {
+	if _, err := service.Invoke(ctx, c, "provider/add", account.String(), service.Caveats{
+		"provider": Provider,
+		"consumer": key.DID().String(),
+	}); err != nil {
+		return fmt.Errorf("provision %s: %w", key.DID(), err)
+	}
+	toAccount, err := delegateAll(key, account, name)
+	if err != nil {
+		return err
+	}
+	link := toAccount.Link().(cidlink.Link)
+	if _, err := service.Invoke(ctx, c, "access/delegate", key.DID().String(), service.Caveats{
+		"delegations": service.Caveats{link.String(): link},
+	}, toAccount); err != nil {
+		return fmt.Errorf("store delegation to %s: %w", account, err)
+	}
+	return nil
+}
+
+// delegateAll delegates every ability in the space of key to audience,
+// naming the space the way other Storacha clients do
+func delegateAll(key ucan.Signer, audience ucan.Principal, name string) (delegation.Delegation, error) {
+	return delegation.Delegate(key, audience,
+		[]ucan.Capability[ucan.NoCaveats]{ucan.NewCapability("*", key.DID().String(), ucan.NoCaveats{})},
+		delegation.WithNoExpiration(),
+		delegation.WithFacts([]ucan.FactBuilder{nameFact(name)}),
+	)
+}
+
+// Info is what the service reports about a space
+type Info struct {
+	Providers []string
+}
+
+// GetInfo asks the service which providers space is provisioned with
+func GetInfo(ctx context.Context, c *client.Client, space did.DID) (Info, error) {
+	out, err := service.Invoke(ctx, c, "space/info", space.String(), service.Caveats{})
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	providers, _ := out["providers"].([]any)
+	for _, p := range providers {
+		if s, ok := p.(string); ok {
+			info.Providers = append(info.Providers, s)
+		}
+	}
+	return info, nil
+}
+
+// Usage returns the bytes stored in space at the end of the period from..to,
+// summed over its providers
+func Usage(ctx context.Context, c *client.Client, space did.DID, from, to time.Time) (uint64, error) {
+	out, err := service.Invoke(ctx, c, "usage/report", space.String(), service.Caveats{
+		"period": service.Caveats{"from": from.Unix(), "to": to.Unix()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, report := range out {
+		r, _ := report.(map[string]any)
+		size, _ := r["size"].(map[string]any)
+		if final, ok := size["final"].(int64); ok && final > 0 {
+			total += uint64(final)
+		}
+	}
+	return total, nil
+}
+
+// nameFact is the {space: {name}} fact naming a space
+type nameFact string
+
+func (n nameFact) ToIPLD() (map[string]datamodel.Node, error) {
+	node, err := service.Caveats{"name": string(n)}.ToIPLD()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]datamodel.Node{"space": node}, nil
+}
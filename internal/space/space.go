@@ -0,0 +1,155 @@
+// Package space lists, provisions and inspects Storacha spaces
+package space
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/storacha/go-ucanto/core/dag/blockstore"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/go-ucanto/ucan"
+)
+
+// Space is a space the agent holds delegations for
+type Space struct {
+	DID       did.DID
+	Name      string    // from the space's own delegation, empty if it has none
+	Abilities []string  // what the agent may do in the space, sorted
+	Expires   time.Time // when the last of the delegations expires; zero if never
+}
+
+// List enumerates the spaces proofs grant access to, sorted by name. Spaces
+// reached through an account's ucan:* delegation, as an email login grants,
+// are included with the abilities the account holds; spaces that only
+// appear as evidence deeper in a proof chain are not.
+func List(proofs []delegation.Delegation) []Space {
+	w := walker{spaces: make(map[did.DID]*Space), names: make(map[did.DID]string)}
+	for _, p := range proofs {
+		if ucan.IsExpired(p) {
+			continue
+		}
+		bs, err := blockstore.NewBlockReader(blockstore.WithBlocksIterator(p.Blocks()))
+		if err != nil {
+			continue
+		}
+		var expires time.Time
+		if exp := p.Expiration(); exp != nil {
+			expires = time.Unix(int64(*exp), 0)
+		}
+		w.visit(p, bs, expires, true)
+	}
+
+	spaces := make([]Space, 0, len(w.spaces))
+	for id, s := range w.spaces {
+		s.Name = w.names[id]
+		sort.Strings(s.Abilities)
+		spaces = append(spaces, *s)
+	}
+	sort.Slice(spaces, func(i, j int) bool {
+		if spaces[i].Name != spaces[j].Name {
+			return spaces[i].Name < spaces[j].Name
+		}
+		return spaces[i].DID.String() < spaces[j].DID.String()
+	})
+	return spaces
+}
+
+// walker collects the spaces held through a set of proof chains, and the
+// names found anywhere in them
+type walker struct {
+	spaces map[did.DID]*Space
+	names  map[did.DID]string
+}
+
+// visit records the spaces d delegates. held is false below a delegation the
+// agent only needs as evidence, whose abilities it does not hold itself.
+func (w *walker) visit(d delegation.Delegation, bs blockstore.BlockReader, expires time.Time, held bool) {
+	forwards := false
+	for _, c := range d.Capabilities() {
+		if c.With() == "ucan:*" {
+			forwards = true
+			continue
+		}
+		if !strings.HasPrefix(c.With(), "did:key:") {
+			continue
+		}
+		id, err := did.Parse(c.With())
+		if err != nil {
+			continue
+		}
+		if d.Issuer().DID() == id && w.names[id] == "" {
+			w.names[id] = spaceName(d)
+		}
+		if !held {
+			continue
+		}
+		s := w.spaces[id]
+		if s == nil {
+			s = &Space{DID: id, Expires: expires}
+			w.spaces[id] = s
+		} else if !s.Expires.IsZero() && (expires.IsZero() || expires.After(s.Expires)) {
+			s.Expires = expires
+		}
+		if !contains(s.Abilities, c.Can()) {
+			s.Abilities = append(s.Abilities, c.Can())
+		}
+	}
+	for _, link := range d.Proofs() {
+		proof, err := delegation.NewDelegationView(link, bs)
+		if err != nil {
+			continue
+		}
+		w.visit(proof, bs, expires, held && forwards)
+	}
+}
+
+// spaceName reads the {space: {name}} fact a space's own delegation carries
+func spaceName(d delegation.Delegation) string {
+	for _, f := range d.Facts() {
+		node, ok := f["space"].(datamodel.Node)
+		if !ok {
+			continue
+		}
+		n, err := node.LookupByString("name")
+		if err != nil {
+			continue
+		}
+		if name, err := n.AsString(); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the DID of the space named s, or s itself if it is a DID
+func Resolve(spaces []Space, s string) (did.DID, error) {
+	if strings.HasPrefix(s, "did:") {
+		return did.Parse(s)
+	}
+	var found []did.DID
+	for _, sp := range spaces {
+		if sp.Name == s {
+			found = append(found, sp.DID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return did.DID{}, fmt.Errorf("no space named %q among the held delegations", s)
+	case 1:
+		return found[0], nil
+	}
+	return did.DID{}, fmt.Errorf("%d spaces are named %q, give the DID instead", len(found), s)
+}
@@ -0,0 +1,117 @@
+package unixfs
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DirOptions controls how ImportDirectory imports a tree
+type DirOptions struct {
+	Params Params
+	// Hidden includes entries whose name starts with ".", which ipfs-car also
+	// skips by default. StateDir is skipped either way.
+	Hidden bool
+
+	// Cached, if set, is asked for each regular file before it is read;
+	// files it returns a link for are not imported again and emit no blocks.
+	// rel is the slash-separated path below the imported directory.
+	Cached func(rel string, info fs.FileInfo) (Link, bool)
+	// Imported, if set, is told the link of every file and directory in the
+	// tree, and of the tree itself as "".
+	Imported func(rel string, info fs.FileInfo, link Link)
+}
+
+// ImportDirectory imports the tree below dir. Entries are visited in name
+// order, symlinks are followed and directories of more than ShardThreshold
+// entries are sharded, as ipfs-car does, so the same tree with the same
+// Params always yields the same root CID.
+func ImportDirectory(dir string, opts DirOptions, sink BlockSink) (Link, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Link{}, err
+	}
+	return importDir(dir, "", info, opts, sink)
+}
+
+func importDir(dir, rel string, info fs.FileInfo, opts DirOptions, sink BlockSink) (Link, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return Link{}, fmt.Errorf("read directory %s: %w", dir, err)
+	}
+
+	entries := make([]DirEntry, 0, len(ents))
+	for _, ent := range ents {
+		name := ent.Name()
+		if name == StateDir || (!opts.Hidden && strings.HasPrefix(name, ".")) {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		childRel := path.Join(rel, name)
+		childInfo, err := os.Stat(p)
+		if err != nil {
+			return Link{}, fmt.Errorf("stat %s: %w", p, err)
+		}
+
+		var link Link
+		switch {
+		case childInfo.IsDir():
+			link, err = importDir(p, childRel, childInfo, opts, sink)
+		case childInfo.Mode().IsRegular():
+			link, err = importFileAt(p, childRel, childInfo, opts, sink)
+		default:
+			log.Printf("Skipping %s: not a regular file or directory", p)
+			continue
+		}
+		if err != nil {
+			return Link{}, err
+		}
+		entries = append(entries, DirEntry{Name: name, Link: link})
+	}
+
+	link, err := BuildDirectory(entries, opts.Params, sink)
+	if err != nil {
+		return Link{}, err
+	}
+	if opts.Imported != nil {
+		opts.Imported(rel, info, link)
+	}
+	return link, nil
+}
+
+func importFileAt(p, rel string, info fs.FileInfo, opts DirOptions, sink BlockSink) (Link, error) {
+	if opts.Cached != nil {
+		if link, ok := opts.Cached(rel, info); ok {
+			if opts.Imported != nil {
+				opts.Imported(rel, info, link)
+			}
+			return link, nil
+		}
+	}
+	link, err := importPath(p, opts.Params, sink)
+	if err != nil {
+		return Link{}, err
+	}
+	if opts.Imported != nil {
+		opts.Imported(rel, info, link)
+	}
+	return link, nil
+}
+
+func importPath(p string, params Params, sink BlockSink) (Link, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return Link{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	link, err := ImportFile(f, params, sink)
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: %w", p, err)
+	}
+	return link, nil
+}
@@ -0,0 +1,200 @@
+// Package unixfs builds UnixFS DAGs from local data. Blocks are handed to a
+// caller-supplied sink as they are produced, so they can be cached, packed
+// into a CAR or uploaded without holding the whole DAG in memory.
+package unixfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	"github.com/ipfs/boxo/ipld/unixfs/hamt"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	h "github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/trickle"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+	format "github.com/ipfs/go-ipld-format"
+)
+
+// defaults match ipfs-car, so the same content gets the same CIDs either way
+const (
+	DefaultChunkSize = 1 << 20 // 1 MiB
+	DefaultMaxLinks  = 1024
+)
+
+// directories with more entries than ShardThreshold are encoded as HAMT
+// shards of hamtFanout buckets, as ipfs-car does; a basic directory that
+// large could outgrow the 1 MiB block limit
+const (
+	ShardThreshold = 1000
+	hamtFanout     = 256
+)
+
+// Link describes the root of an imported file or directory
+type Link struct {
+	Cid   ipfscid.Cid
+	Size  uint64 // file size in bytes, 0 for directories
+	Tsize uint64 // cumulative size of the serialized DAG, as recorded in parent links
+}
+
+// BlockSink receives every block of an import, children before their parents
+type BlockSink func(blocks.Block) error
+
+// ImportFile chunks r into a UnixFS file DAG as described by p
+func ImportFile(r io.Reader, p Params, sink BlockSink) (Link, error) {
+	spl, err := chunker.FromString(r, p.Chunker)
+	if err != nil {
+		return Link{}, fmt.Errorf("invalid chunker %q: %w", p.Chunker, err)
+	}
+	params := h.DagBuilderParams{
+		Dagserv:    &sinkDAG{sink: sink},
+		Maxlinks:   p.MaxLinks,
+		RawLeaves:  p.RawLeaves,
+		CidBuilder: p.cidBuilder(),
+	}
+	db, err := params.New(spl)
+	if err != nil {
+		return Link{}, fmt.Errorf("create DAG builder: %w", err)
+	}
+	var root format.Node
+	if p.Layout == LayoutTrickle {
+		root, err = trickle.Layout(db)
+	} else {
+		root, err = balanced.Layout(db)
+	}
+	if err != nil {
+		return Link{}, fmt.Errorf("import file: %w", err)
+	}
+	return fileLink(root)
+}
+
+func fileLink(nd format.Node) (Link, error) {
+	tsize, err := nd.Size()
+	if err != nil {
+		return Link{}, err
+	}
+	link := Link{Cid: nd.Cid(), Tsize: tsize}
+	switch n := nd.(type) {
+	case *merkledag.RawNode:
+		link.Size = uint64(len(n.RawData()))
+	case *merkledag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(n.Data())
+		if err != nil {
+			return Link{}, fmt.Errorf("decode file root %s: %w", nd.Cid(), err)
+		}
+		link.Size = fsn.FileSize()
+	}
+	return link, nil
+}
+
+// DirEntry is a named child of a directory being built
+type DirEntry struct {
+	Name string
+	Link Link
+}
+
+// BuildDirectory encodes a UnixFS directory holding entries, passes its
+// blocks to sink and returns its link. Directories of more than
+// ShardThreshold entries are HAMT-sharded.
+func BuildDirectory(entries []DirEntry, p Params, sink BlockSink) (Link, error) {
+	if len(entries) > ShardThreshold {
+		return buildShardedDirectory(entries, p, sink)
+	}
+	nd := merkledag.NodeWithData(ft.FolderPBData())
+	if err := nd.SetCidBuilder(p.cidBuilder()); err != nil {
+		return Link{}, err
+	}
+	for _, e := range entries {
+		if err := nd.AddRawLink(e.Name, &format.Link{Name: e.Name, Size: e.Link.Tsize, Cid: e.Link.Cid}); err != nil {
+			return Link{}, fmt.Errorf("add %q: %w", e.Name, err)
+		}
+	}
+	// links are sorted by name on encode, so the CID does not depend on entry order
+	if err := sink(nd); err != nil {
+		return Link{}, err
+	}
+	tsize, err := nd.Size()
+	if err != nil {
+		return Link{}, err
+	}
+	return Link{Cid: nd.Cid(), Tsize: tsize}, nil
+}
+
+// buildShardedDirectory encodes entries as a HAMT sharded directory; the
+// shards are passed to sink below their parents
+func buildShardedDirectory(entries []DirEntry, p Params, sink BlockSink) (Link, error) {
+	// the HAMT adds each sub-shard twice, once for its node and once for its link
+	added := make(map[string]struct{})
+	once := func(b blocks.Block) error {
+		if _, ok := added[b.Cid().KeyString()]; ok {
+			return nil
+		}
+		added[b.Cid().KeyString()] = struct{}{}
+		return sink(b)
+	}
+	shard, err := hamt.NewShard(&sinkDAG{sink: once}, hamtFanout)
+	if err != nil {
+		return Link{}, err
+	}
+	shard.SetCidBuilder(p.cidBuilder())
+	for _, e := range entries {
+		if err := shard.SetLink(context.Background(), e.Name, &format.Link{Name: e.Name, Size: e.Link.Tsize, Cid: e.Link.Cid}); err != nil {
+			return Link{}, fmt.Errorf("add %q: %w", e.Name, err)
+		}
+	}
+	// Node adds every shard to the DAG service, children first
+	nd, err := shard.Node()
+	if err != nil {
+		return Link{}, err
+	}
+	tsize, err := nd.Size()
+	if err != nil {
+		return Link{}, err
+	}
+	return Link{Cid: nd.Cid(), Tsize: tsize}, nil
+}
+
+// sinkDAG is the write-only DAGService the boxo importer builds into
+type sinkDAG struct {
+	sink BlockSink
+}
+
+var _ format.DAGService = (*sinkDAG)(nil)
+
+func (s *sinkDAG) Add(ctx context.Context, nd format.Node) error {
+	return s.sink(nd)
+}
+
+func (s *sinkDAG) AddMany(ctx context.Context, nds []format.Node) error {
+	for _, nd := range nds {
+		if err := s.sink(nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sinkDAG) Get(ctx context.Context, c ipfscid.Cid) (format.Node, error) {
+	return nil, format.ErrNotFound{Cid: c}
+}
+
+func (s *sinkDAG) GetMany(ctx context.Context, cids []ipfscid.Cid) <-chan *format.NodeOption {
+	out := make(chan *format.NodeOption, len(cids))
+	for _, c := range cids {
+		out <- &format.NodeOption{Err: format.ErrNotFound{Cid: c}}
+	}
+	close(out)
+	return out
+}
+
+func (s *sinkDAG) Remove(ctx context.Context, c ipfscid.Cid) error {
+	return nil
+}
+
+func (s *sinkDAG) RemoveMany(ctx context.Context, cids []ipfscid.Cid) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package unixfs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ft "github.com/ipfs/boxo/ipld/unixfs"
+	unixfspb "github.com/ipfs/boxo/ipld/unixfs/pb"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+func collect(out *[]blocks.Block) BlockSink {
+	return func(b blocks.Block) error {
+		*out = append(*out, b)
+		return nil
+	}
+}
+
+func TestImportFileKnownCID(t *testing.T) {
+	link, err := ImportFile(strings.NewReader("hello world"), DefaultParams(), collect(new([]blocks.Block)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a single raw leaf, as ipfs-car and kubo --cid-version 1 give
+	if want := "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"; link.Cid.String() != want {
+		t.Errorf("root = %s, want %s", link.Cid, want)
+	}
+	if link.Size != 11 {
+		t.Errorf("size = %d, want 11", link.Size)
+	}
+}
+
+func TestBuildDirectoryEmpty(t *testing.T) {
+	v0 := DefaultParams()
+	v0.RawLeaves, v0.CIDVersion = false, 0
+	for _, tt := range []struct {
+		params Params
+		want   string
+	}{
+		{DefaultParams(), "bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354"},
+		{v0, "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"},
+	} {
+		link, err := BuildDirectory(nil, tt.params, collect(new([]blocks.Block)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.Cid.String() != tt.want {
+			t.Errorf("CIDv%d empty directory = %s, want %s", tt.params.CIDVersion, link.Cid, tt.want)
+		}
+	}
+}
+
+// TestBuildShardedDirectoryKnownCID rebuilds the two level HAMT of the
+// hamtDir fixture in boxo's gateway tests, whose names collide in the first
+// level, and compares its root with the one recorded there
+func TestBuildShardedDirectoryKnownCID(t *testing.T) {
+	entries := []DirEntry{
+		{"exampleA", Link{Cid: ipfscid.MustParse("bafybeigcisqd7m5nf3qmuvjdbakl5bdnh4ocrmacaqkpuh77qjvggmt2sa"), Tsize: 1271}},
+		{"exampleB", Link{Cid: ipfscid.MustParse("bafybeid3trcauvcp7fxaai23gkz3qexmlfxnnejgwm57hdvre472dafvha"), Tsize: 1084}},
+		{"exampleC-hamt-collide-exampleA-seed-52", Link{Cid: ipfscid.MustParse("bafkreidqhbqn5htm5qejxpb3hps7dookudo3nncfn6al6niqibi5lq6fee"), Tsize: 962}},
+		{"exampleD-hamt-collide-exampleB-seed-364", Link{Cid: ipfscid.MustParse("bafkreih2grj7p2bo5yk2guqazxfjzapv6hpm3mwrinv6s3cyayd72ke5he"), Tsize: 1283}},
+	}
+	var blks []blocks.Block
+	link, err := buildShardedDirectory(entries, DefaultParams(), collect(&blks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "bafybeignui4g7l6cvqyy4t6vnbl2fjtego4ejmpcia77jhhwnksmm4bejm"; link.Cid.String() != want {
+		t.Errorf("root = %s, want %s", link.Cid, want)
+	}
+	// two sub-shards, then the root
+	if len(blks) != 3 || !blks[2].Cid().Equals(link.Cid) {
+		t.Errorf("emitted %d blocks, want 3 ending with the root", len(blks))
+	}
+}
+
+func TestBuildDirectorySharding(t *testing.T) {
+	entries := func(n, nameLen int) []DirEntry {
+		out := make([]DirEntry, n)
+		for i := range out {
+			name := fmt.Sprintf("%0*d", nameLen, i)
+			c, err := merkledag.V1CidPrefix().Sum([]byte(name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[i] = DirEntry{Name: name, Link: Link{Cid: c, Tsize: 10}}
+		}
+		return out
+	}
+	rootType := func(blks []blocks.Block) unixfspb.Data_DataType {
+		nd, err := merkledag.DecodeProtobuf(blks[len(blks)-1].RawData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsn, err := ft.FSNodeFromBytes(nd.Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fsn.Type()
+	}
+
+	for _, tt := range []struct {
+		n    int
+		want unixfspb.Data_DataType
+	}{
+		{ShardThreshold, unixfspb.Data_Directory},
+		{ShardThreshold + 1, unixfspb.Data_HAMTShard},
+	} {
+		var blks []blocks.Block
+		if _, err := BuildDirectory(entries(tt.n, 8), DefaultParams(), collect(&blks)); err != nil {
+			t.Fatal(err)
+		}
+		if got := rootType(blks); got != tt.want {
+			t.Errorf("%d entries: root is a %s, want %s", tt.n, got, tt.want)
+		}
+	}
+
+	// long names in a large directory stay far below the block size limit
+	var blks []blocks.Block
+	if _, err := BuildDirectory(entries(20000, 255), DefaultParams(), collect(&blks)); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blks {
+		if len(b.RawData()) > 1<<20 {
+			t.Errorf("block %s is %d bytes", b.Cid(), len(b.RawData()))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package unixfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// DAG layouts supported by ImportFile
+const (
+	LayoutBalanced = "balanced"
+	LayoutTrickle  = "trickle"
+)
+
+// StateDir is the per-source directory StorachaFS keeps its own metadata in;
+// it is never imported
+const StateDir = ".storacha"
+
+// Params controls how files are chunked and laid out as UnixFS DAGs. The
+// same content imported with the same Params always gets the same CIDs.
+type Params struct {
+	// Chunker uses the kubo syntax: "size-<bytes>", "rabin-<min>-<avg>-<max>" or "buzhash"
+	Chunker    string `json:"chunker"`
+	Layout     string `json:"layout"`
+	RawLeaves  bool   `json:"rawLeaves"`
+	CIDVersion int    `json:"cidVersion"`
+	MaxLinks   int    `json:"maxLinks"`
+}
+
+// DefaultParams matches ipfs-car: 1 MiB fixed chunks, raw leaves, CIDv1 and
+// a balanced layout 1024 links wide. Directory sharding does not depend on
+// Params; see ShardThreshold.
+func DefaultParams() Params {
+	return Params{
+		Chunker:    fmt.Sprintf("size-%d", DefaultChunkSize),
+		Layout:     LayoutBalanced,
+		RawLeaves:  true,
+		CIDVersion: 1,
+		MaxLinks:   DefaultMaxLinks,
+	}
+}
+
+// Validate reports the first setting that cannot be used
+func (p Params) Validate() error {
+	if _, err := chunker.FromString(strings.NewReader(""), p.Chunker); err != nil {
+		return fmt.Errorf("invalid chunker %q: %w", p.Chunker, err)
+	}
+	if p.Layout != LayoutBalanced && p.Layout != LayoutTrickle {
+		return fmt.Errorf("invalid layout %q: must be %s or %s", p.Layout, LayoutBalanced, LayoutTrickle)
+	}
+	switch p.CIDVersion {
+	case 0:
+		if p.RawLeaves {
+			return fmt.Errorf("CIDv0 cannot address raw leaves; disable raw leaves or use CIDv1")
+		}
+	case 1:
+	default:
+		return fmt.Errorf("invalid CID version %d: must be 0 or 1", p.CIDVersion)
+	}
+	if p.MaxLinks < 2 {
+		return fmt.Errorf("invalid max links %d: must be at least 2", p.MaxLinks)
+	}
+	return nil
+}
+
+func (p Params) cidBuilder() ipfscid.Builder {
+	if p.CIDVersion == 0 {
+		return merkledag.V0CidPrefix()
+	}
+	return merkledag.V1CidPrefix()
+}
+
+// ParamsPath is where the import parameters of a source directory are recorded
+func ParamsPath(source string) string {
+	return filepath.Join(source, StateDir, "import.json")
+}
+
+// LoadParams reads recorded import parameters; ok is false if none were recorded
+func LoadParams(path string) (p Params, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Params{}, false, nil
+	}
+	if err != nil {
+		return Params{}, false, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Params{}, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return p, true, p.Validate()
+}
+
+// Save records p at path so later imports of the same source reproduce its CIDs
+func (p Params) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,56 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+
+	ipfscid "github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	spaceindex "github.com/storacha/go-libstoracha/capabilities/space/index"
+	uclient "github.com/storacha/go-ucanto/client"
+	"github.com/storacha/go-ucanto/core/delegation"
+	"github.com/storacha/go-ucanto/core/invocation"
+	"github.com/storacha/go-ucanto/core/result"
+)
+
+// addIndex registers a stored sharded DAG index with space/index/add so the
+// blocks of an upload can be located; guppy has no client method for it yet
+func (g guppyService) addIndex(ctx context.Context, index ipfscid.Cid) error {
+	proofs := make([]delegation.Proof, 0, len(g.client.Proofs()))
+	for _, d := range g.client.Proofs() {
+		proofs = append(proofs, delegation.FromDelegation(d))
+	}
+
+	conn := g.client.Connection()
+	inv, err := spaceindex.Add.Invoke(
+		g.client.Issuer(),
+		conn.ID(),
+		g.space.String(),
+		spaceindex.AddCaveats{Index: cidlink.Link{Cid: index}},
+		delegation.WithProof(proofs...),
+	)
+	if err != nil {
+		return fmt.Errorf("generating space/index/add invocation: %w", err)
+	}
+
+	resp, err := uclient.Execute(ctx, []invocation.Invocation{inv}, conn)
+	if err != nil {
+		return fmt.Errorf("sending space/index/add invocation: %w", err)
+	}
+	rcptLink, ok := resp.Get(inv.Link())
+	if !ok {
+		return fmt.Errorf("space/index/add receipt not found: %s", inv.Link())
+	}
+	reader, err := spaceindex.NewAddReceiptReader()
+	if err != nil {
+		return fmt.Errorf("space/index/add receipt reader: %w", err)
+	}
+	rcpt, err := reader.Read(rcptLink, resp.Blocks())
+	if err != nil {
+		return fmt.Errorf("reading space/index/add receipt: %w", err)
+	}
+	if _, fail := result.Unwrap(rcpt.Out()); fail != nil {
+		return fmt.Errorf("space/index/add failed: %w", fail)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// ErrNoJournal is returned by Resume when source has no interrupted upload
+var ErrNoJournal = errors.New("no interrupted upload to resume")
+
+// JournalPath is where an upload of source keeps its journal: in the user
+// cache directory, keyed by the absolute path of source, so uploading never
+// writes into the source
+func JournalPath(source string) (string, error) {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return "", err
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(base, "storachafs", "uploads", hex.EncodeToString(sum[:16])+".json"), nil
+}
+
+// HasJournal reports whether an upload of source was interrupted
+func HasJournal(source string) bool {
+	path, err := JournalPath(source)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// journal records how far an upload got. Shards are stored as soon as they
+// are filled and only their CIDs are kept, so a resumed upload imports the
+// source again and skips the shards the service already accepted. Once every
+// shard and the index are stored, each remaining step is saved as soon as the
+// service accepts it, so a resumed upload repeats at most one request.
+type journal struct {
+	path string // empty for uploads that cannot be resumed
+
+	Space     string        `json:"space"`
+	Params    unixfs.Params `json:"params"`
+	Hidden    bool          `json:"hidden"`
+	ShardSize int64         `json:"shardSize"`
+	// Changes marks a sync, whose blocks only the same sync produces again
+	Changes bool `json:"changes,omitempty"`
+
+	// Stored lists every shard the service accepted, in any run
+	Stored []ipfscid.Cid `json:"stored"`
+
+	// set once the index is stored
+	Root       ipfscid.Cid   `json:"root"`
+	Shards     []ipfscid.Cid `json:"shards,omitempty"`
+	Index      ipfscid.Cid   `json:"index"`
+	IndexAdded bool          `json:"indexAdded"`
+	Registered bool          `json:"registered"`
+}
+
+func loadJournal(path string) (*journal, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoJournal
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("parse upload journal: %w", err)
+	}
+	return j, nil
+}
+
+// save replaces the journal atomically so a crash never leaves it half written
+func (j *journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("write upload journal: %w", err)
+	}
+	if err := atomicfile.WriteFile(j.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write upload journal: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the journal of a finished upload
+func (j *journal) remove() {
+	if j.path == "" {
+		return
+	}
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Failed to remove upload journal %s: %v", j.path, err)
+	}
+}
+
+// stored reports whether the service accepted the shard c
+func (j *journal) stored(c ipfscid.Cid) bool {
+	for _, s := range j.Stored {
+		if s.Equals(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *journal) result() Result {
+	return Result{Root: j.Root, Shards: j.Shards, Index: j.Index}
+}
+
+// carCID is the CID a blob of CAR bytes is stored under
+func carCID(data []byte) (ipfscid.Cid, error) {
+	digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	if err != nil {
+		return ipfscid.Undef, err
+	}
+	return ipfscid.NewCidV1(uint64(multicodec.Car), digest), nil
+}
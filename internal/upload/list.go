@@ -0,0 +1,51 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ipfscid "github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	uploadcap "github.com/storacha/go-libstoracha/capabilities/upload"
+)
+
+// ErrNoUploads is returned by Latest for a space nothing was uploaded to
+var ErrNoUploads = errors.New("space has no uploads")
+
+// listPageSize is how many uploads are requested per upload/list call
+const listPageSize = 100
+
+// Latest returns the root of the upload most recently registered in the
+// space, which after a sync is the current version of the synced tree
+func (s *Space) Latest(ctx context.Context) (ipfscid.Cid, error) {
+	var (
+		latest uploadcap.ListItem
+		found  bool
+		cursor *string
+	)
+	size := uint64(listPageSize)
+	for {
+		page, err := s.client.UploadList(ctx, s.space, uploadcap.ListCaveats{Cursor: cursor, Size: &size})
+		if err != nil {
+			return ipfscid.Undef, fmt.Errorf("UploadList failed: %w", err)
+		}
+		for _, item := range page.Results {
+			if !found || item.UpdatedAt.After(latest.UpdatedAt) {
+				latest, found = item, true
+			}
+		}
+		if page.Cursor == nil || len(page.Results) == 0 {
+			break
+		}
+		cursor = page.Cursor
+	}
+	if !found {
+		return ipfscid.Undef, ErrNoUploads
+	}
+	link, ok := latest.Root.(cidlink.Link)
+	if !ok {
+		return ipfscid.Undef, fmt.Errorf("unexpected upload root %s", latest.Root)
+	}
+	return link.Cid, nil
+}
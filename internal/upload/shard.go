@@ -0,0 +1,100 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+	car "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-libstoracha/blobindex"
+)
+
+// DefaultShardSize is the largest CAR shard stored as one blob, the same
+// limit the official Storacha clients use
+const DefaultShardSize = 133_169_152
+
+// slice locates one block's bytes inside a shard
+type slice struct {
+	block multihash.Multihash
+	pos   blobindex.Position
+}
+
+// shardBuilder collects blocks into CARv1 shards of at most size bytes and
+// hands each full shard to emit. Only blocks of the shard being filled are
+// held in memory.
+type shardBuilder struct {
+	size int64
+	emit func(car []byte, slices []slice) error
+
+	body   bytes.Buffer // block sections of the current shard
+	slices []slice      // offsets relative to the start of body
+	seen   map[string]struct{}
+}
+
+func newShardBuilder(size int64, emit func([]byte, []slice) error) *shardBuilder {
+	if size <= 0 {
+		size = DefaultShardSize
+	}
+	return &shardBuilder{size: size, emit: emit, seen: make(map[string]struct{})}
+}
+
+// headerReserve covers a CARv1 header naming one root
+const headerReserve = 64
+
+func (s *shardBuilder) add(b blocks.Block) error {
+	key := b.Cid().KeyString()
+	if _, dup := s.seen[key]; dup {
+		return nil
+	}
+	s.seen[key] = struct{}{}
+
+	cidBytes := b.Cid().Bytes()
+	section := int64(carutil.LdSize(cidBytes, b.RawData()))
+	if s.body.Len() > 0 && headerReserve+int64(s.body.Len())+section > s.size {
+		if err := s.flush(nil); err != nil {
+			return err
+		}
+	}
+
+	dataEnd := int64(s.body.Len()) + section
+	if err := carutil.LdWrite(&s.body, cidBytes, b.RawData()); err != nil {
+		return err
+	}
+	s.slices = append(s.slices, slice{
+		block: b.Cid().Hash(),
+		pos:   blobindex.Position{Offset: uint64(dataEnd) - uint64(len(b.RawData())), Length: uint64(len(b.RawData()))},
+	})
+	return nil
+}
+
+// flush emits the current shard; the final shard of an upload names its root
+func (s *shardBuilder) flush(roots []ipfscid.Cid) error {
+	if s.body.Len() == 0 && len(roots) == 0 {
+		return nil
+	}
+	header := &car.CarHeader{Roots: roots, Version: 1}
+	headerSize, err := car.HeaderSize(header)
+	if err != nil {
+		return fmt.Errorf("size CAR header: %w", err)
+	}
+
+	var shard bytes.Buffer
+	shard.Grow(int(headerSize) + s.body.Len())
+	if err := car.WriteHeader(header, &shard); err != nil {
+		return fmt.Errorf("write CAR header: %w", err)
+	}
+	shard.Write(s.body.Bytes())
+	for i := range s.slices {
+		s.slices[i].pos.Offset += headerSize
+	}
+
+	if err := s.emit(shard.Bytes(), s.slices); err != nil {
+		return err
+	}
+	s.body.Reset()
+	s.slices = nil
+	return nil
+}
@@ -0,0 +1,118 @@
+package upload
+
+import (
+	"bytes"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/multiformats/go-multihash"
+)
+
+func rawBlock(t *testing.T, data []byte) blocks.Block {
+	t.Helper()
+	c, err := ipfscid.V1Builder{Codec: ipfscid.Raw, MhType: multihash.SHA2_256}.Sum(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestShardBuilder(t *testing.T) {
+	const maxSize = 1024
+	var blks []blocks.Block
+	for i := 0; i < 40; i++ {
+		// 50 to 245 bytes, so several blocks share each shard
+		blks = append(blks, rawBlock(t, bytes.Repeat([]byte{byte(i)}, 50+i*5)))
+	}
+	root := blks[len(blks)-1].Cid()
+
+	type shard struct {
+		car    []byte
+		slices []slice
+	}
+	var shards []shard
+	b := newShardBuilder(maxSize, func(car []byte, slices []slice) error {
+		shards = append(shards, shard{append([]byte(nil), car...), append([]slice(nil), slices...)})
+		return nil
+	})
+	for _, blk := range blks {
+		if err := b.add(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a repeated block is stored once
+	if err := b.add(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.flush([]ipfscid.Cid{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shards) < 2 {
+		t.Fatalf("built %d shards, want several", len(shards))
+	}
+	seen := make(map[string]int)
+	for i, sh := range shards {
+		if len(sh.car) > maxSize {
+			t.Errorf("shard %d is %d bytes, over the %d byte limit", i, len(sh.car), maxSize)
+		}
+		for _, sl := range sh.slices {
+			end := sl.pos.Offset + sl.pos.Length
+			if end > uint64(len(sh.car)) {
+				t.Fatalf("shard %d: slice %d+%d is past its end (%d bytes)", i, sl.pos.Offset, sl.pos.Length, len(sh.car))
+			}
+			data := sh.car[sl.pos.Offset:end]
+			got, err := multihash.Sum(data, multihash.SHA2_256, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, sl.block) {
+				t.Errorf("shard %d: bytes at %d+%d do not hash to the indexed block", i, sl.pos.Offset, sl.pos.Length)
+			}
+			seen[string(sl.block)]++
+		}
+	}
+	for i, blk := range blks {
+		if n := seen[string(blk.Cid().Hash())]; n != 1 {
+			t.Errorf("block %d is indexed %d times, want once", i, n)
+		}
+	}
+	if len(seen) != len(blks) {
+		t.Errorf("index holds %d blocks, want %d", len(seen), len(blks))
+	}
+}
+
+func TestShardBuilderLastShardNamesRoot(t *testing.T) {
+	blk := rawBlock(t, []byte("root"))
+	var got []byte
+	b := newShardBuilder(DefaultShardSize, func(car []byte, _ []slice) error {
+		got = car
+		return nil
+	})
+	if err := b.add(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.flush([]ipfscid.Cid{blk.Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	br, err := carv2.NewBlockReader(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(br.Roots) != 1 || !br.Roots[0].Equals(blk.Cid()) {
+		t.Errorf("roots = %v, want [%s]", br.Roots, blk.Cid())
+	}
+	read, err := br.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.Cid().Equals(blk.Cid()) {
+		t.Errorf("block = %s, want %s", read.Cid(), blk.Cid())
+	}
+}
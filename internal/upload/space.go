@@ -0,0 +1,253 @@
+// Package upload stores content in a Storacha space
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-libstoracha/blobindex"
+	"github.com/storacha/go-ucanto/did"
+	"github.com/storacha/guppy/pkg/client"
+)
+
+// Space uploads to one space with an authenticated guppy client
+type Space struct {
+	client    *client.Client
+	service   service
+	space     did.DID
+	shardSize int64
+	debug     bool
+}
+
+func NewSpace(c *client.Client, space did.DID, debug bool) *Space {
+	return &Space{client: c, service: guppyService{client: c, space: space}, space: space, shardSize: DefaultShardSize, debug: debug}
+}
+
+// service is what an upload asks of the Storacha service
+type service interface {
+	// storeBlob adds a CAR blob to the space and checks it was stored under c
+	storeBlob(ctx context.Context, data []byte, c ipfscid.Cid) error
+	// addIndex registers a stored sharded DAG index with space/index/add
+	addIndex(ctx context.Context, index ipfscid.Cid) error
+	// register ties the stored shards to root with upload/add
+	register(ctx context.Context, root ipfscid.Cid, shards []ipfscid.Cid) error
+}
+
+// guppyService talks to the service with an authenticated guppy client
+type guppyService struct {
+	client *client.Client
+	space  did.DID
+}
+
+// WithShardSize sets the maximum size of the CAR shards uploads are split into
+func (s *Space) WithShardSize(size int64) *Space {
+	s.shardSize = size
+	return s
+}
+
+// Result describes a completed upload
+type Result struct {
+	Root   ipfscid.Cid   // UnixFS root of the uploaded content
+	Shards []ipfscid.Cid // CAR shards stored in the space, in upload order
+	Index  ipfscid.Cid   // sharded DAG index locating every block in the shards
+}
+
+// UploadDirectory imports dir and uploads it. Blocks are packed into CAR
+// shards in memory and each shard is stored as soon as it is full, so memory
+// use is bounded by the shard size and no disk space is needed. Progress is
+// journaled, and an interrupted upload can be continued with Resume.
+func (s *Space) UploadDirectory(ctx context.Context, dir string, opts unixfs.DirOptions) (Result, error) {
+	return s.start(ctx, dir, opts, false, importDirectory(dir, opts))
+}
+
+// UploadChanges uploads a new version of dir from the blocks produce emits,
+// which may leave out blocks the space already holds. It is journaled like
+// UploadDirectory, but only calling it again with the same changes continues
+// an interrupted upload: shards already stored are then not sent again.
+// Resume, which imports dir in full, would produce other shards and refuses.
+func (s *Space) UploadChanges(ctx context.Context, dir string, opts unixfs.DirOptions, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) (Result, error) {
+	return s.start(ctx, dir, opts, true, produce)
+}
+
+// start journals a new upload of dir, keeping the shards an interrupted
+// upload of it to the same space already stored, and runs it
+func (s *Space) start(ctx context.Context, dir string, opts unixfs.DirOptions, changes bool, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) (Result, error) {
+	path, err := JournalPath(dir)
+	if err != nil {
+		return Result{}, err
+	}
+	j := &journal{path: path, Space: s.space.String(), Params: opts.Params, Hidden: opts.Hidden, ShardSize: s.shardSize, Changes: changes}
+	if old, err := loadJournal(path); err == nil && old.Space == j.Space {
+		log.Printf("Continuing interrupted upload of %s: %d shards already stored", dir, len(old.Stored))
+		j.Stored = old.Stored
+	}
+	if err := j.save(); err != nil {
+		return Result{}, err
+	}
+	return s.run(ctx, j, produce)
+}
+
+// Resume continues the interrupted upload of dir recorded in its journal,
+// with the import settings and shard size it was started with
+func (s *Space) Resume(ctx context.Context, dir string) (Result, error) {
+	path, err := JournalPath(dir)
+	if err != nil {
+		return Result{}, err
+	}
+	j, err := loadJournal(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if j.Space != s.space.String() {
+		return Result{}, fmt.Errorf("interrupted upload of %s targets space %s, not %s", dir, j.Space, s.space)
+	}
+	if j.Changes && !j.Index.Defined() {
+		return Result{}, fmt.Errorf("interrupted upload of %s was a sync; run `storachafs sync` again to continue it", dir)
+	}
+	if j.Index.Defined() {
+		log.Printf("Resuming upload of %s: all %d shards and the index are stored", dir, len(j.Shards))
+	} else {
+		log.Printf("Resuming upload of %s: importing it again, %d shards are already stored", dir, len(j.Stored))
+	}
+	s.shardSize = j.ShardSize
+	return s.run(ctx, j, importDirectory(dir, unixfs.DirOptions{Params: j.Params, Hidden: j.Hidden}))
+}
+
+func importDirectory(dir string, opts unixfs.DirOptions) func(unixfs.BlockSink) (ipfscid.Cid, error) {
+	return func(sink unixfs.BlockSink) (ipfscid.Cid, error) {
+		link, err := unixfs.ImportDirectory(dir, opts, sink)
+		return link.Cid, err
+	}
+}
+
+// Publish uploads the blocks produce emits as the new version of the DAG
+// under the root it returns, storing each shard as soon as it is full
+func (s *Space) Publish(ctx context.Context, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) error {
+	j := &journal{Space: s.space.String(), ShardSize: s.shardSize}
+	_, err := s.run(ctx, j, produce)
+	return err
+}
+
+// run stores the shards and index of the blocks produce emits unless the
+// journal says that already happened, then indexes them with space/index/add
+// and registers the root with upload/add, as the official clients do. The
+// journal is removed once the upload is registered.
+func (s *Space) run(ctx context.Context, j *journal, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) (Result, error) {
+	if !j.Index.Defined() {
+		if err := s.storeShards(ctx, j, produce); err != nil {
+			return Result{}, err
+		}
+	}
+	if !j.IndexAdded {
+		if err := s.service.addIndex(ctx, j.Index); err != nil {
+			return Result{}, err
+		}
+		j.IndexAdded = true
+		if err := j.save(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if !j.Registered {
+		if err := s.service.register(ctx, j.Root, j.Shards); err != nil {
+			return Result{}, err
+		}
+		j.Registered = true
+		if err := j.save(); err != nil {
+			return Result{}, err
+		}
+	}
+	if s.debug {
+		log.Printf("Registered upload %s with %d shards and index %s", j.Root, len(j.Shards), j.Index)
+	}
+	j.remove()
+	return j.result(), nil
+}
+
+// storeShards packs the blocks produce emits into CAR shards and stores each
+// one as soon as it is full, skipping those the journal records as stored,
+// then stores a sharded DAG index locating every block in them
+func (s *Space) storeShards(ctx context.Context, j *journal, produce func(unixfs.BlockSink) (ipfscid.Cid, error)) error {
+	var shards []ipfscid.Cid
+	slices := make(map[string][]slice) // by shard multihash
+	builder := newShardBuilder(j.ShardSize, func(car []byte, sl []slice) error {
+		shard, err := carCID(car)
+		if err != nil {
+			return err
+		}
+		shards = append(shards, shard)
+		slices[string(shard.Hash())] = sl
+		if j.stored(shard) {
+			log.Printf("Shard %d already stored: %s", len(shards), shard)
+			return nil
+		}
+		if err := s.service.storeBlob(ctx, car, shard); err != nil {
+			return fmt.Errorf("store shard %d: %w", len(shards), err)
+		}
+		log.Printf("Stored shard %d: %s (%d blocks, %d bytes)", len(shards), shard, len(sl), len(car))
+		j.Stored = append(j.Stored, shard)
+		return j.save()
+	})
+
+	root, err := produce(builder.add)
+	if err != nil {
+		return err
+	}
+	if err := builder.flush([]ipfscid.Cid{root}); err != nil {
+		return err
+	}
+
+	index := blobindex.NewShardedDagIndexView(cidlink.Link{Cid: root}, len(shards))
+	for _, sh := range shards {
+		for _, sl := range slices[string(sh.Hash())] {
+			index.SetSlice(sh.Hash(), sl.block, sl.pos)
+		}
+	}
+	archive, err := index.Archive()
+	if err != nil {
+		return fmt.Errorf("archive index: %w", err)
+	}
+	indexBytes, err := io.ReadAll(archive)
+	if err != nil {
+		return fmt.Errorf("archive index: %w", err)
+	}
+	indexCID, err := carCID(indexBytes)
+	if err != nil {
+		return err
+	}
+	if err := s.service.storeBlob(ctx, indexBytes, indexCID); err != nil {
+		return fmt.Errorf("store index: %w", err)
+	}
+
+	j.Root, j.Shards, j.Index = root, shards, indexCID
+	return j.save()
+}
+
+func (g guppyService) storeBlob(ctx context.Context, data []byte, c ipfscid.Cid) error {
+	digest, _, err := g.client.SpaceBlobAdd(ctx, bytes.NewReader(data), g.space)
+	if err != nil {
+		return fmt.Errorf("SpaceBlobAdd failed: %w", err)
+	}
+	if !bytes.Equal(digest, c.Hash()) {
+		return fmt.Errorf("service stored %s as %s", c, digest.B58String())
+	}
+	return nil
+}
+
+func (g guppyService) register(ctx context.Context, root ipfscid.Cid, shards []ipfscid.Cid) error {
+	shardLinks := make([]ipld.Link, 0, len(shards))
+	for _, sh := range shards {
+		shardLinks = append(shardLinks, cidlink.Link{Cid: sh})
+	}
+	if _, err := g.client.UploadAdd(ctx, g.space, cidlink.Link{Cid: root}, shardLinks); err != nil {
+		return fmt.Errorf("UploadAdd failed: %w", err)
+	}
+	return nil
+}
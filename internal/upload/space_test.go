@@ -0,0 +1,164 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/storacha/go-ucanto/principal/ed25519/signer"
+)
+
+// fakeService records the blobs it stores and fails once it has stored
+// failAfter of them, like a connection lost mid-upload
+type fakeService struct {
+	failAfter int // -1 never fails
+	blobs     []ipfscid.Cid
+	indexes   []ipfscid.Cid
+	root      ipfscid.Cid
+	shards    []ipfscid.Cid
+}
+
+func (f *fakeService) storeBlob(_ context.Context, _ []byte, c ipfscid.Cid) error {
+	if f.failAfter >= 0 && len(f.blobs) == f.failAfter {
+		return errors.New("connection reset")
+	}
+	f.blobs = append(f.blobs, c)
+	return nil
+}
+
+func (f *fakeService) addIndex(_ context.Context, index ipfscid.Cid) error {
+	f.indexes = append(f.indexes, index)
+	return nil
+}
+
+func (f *fakeService) register(_ context.Context, root ipfscid.Cid, shards []ipfscid.Cid) error {
+	f.root, f.shards = root, shards
+	return nil
+}
+
+// testSource writes a directory whose upload takes several 4 KiB shards
+func testSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 12; i++ {
+		data := make([]byte, 1500)
+		rng.Read(data)
+		if err := os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".bin"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testSpace(t *testing.T, svc service) *Space {
+	t.Helper()
+	key, err := signer.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Space{service: svc, space: key.DID(), shardSize: 4096}
+}
+
+func TestResumeSendsOnlyRemainingShards(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := testSource(t)
+	opts := unixfs.DirOptions{Params: unixfs.DefaultParams()}
+
+	const interruptAfter = 2
+	first := &fakeService{failAfter: interruptAfter}
+	s := testSpace(t, first)
+	if _, err := s.UploadDirectory(context.Background(), dir, opts); err == nil {
+		t.Fatal("upload succeeded despite the failing service")
+	}
+	if !HasJournal(dir) {
+		t.Fatal("interrupted upload left no journal")
+	}
+
+	second := &fakeService{failAfter: -1}
+	s.service = second
+	s.shardSize = 0 // Resume must take the shard size from the journal
+	res, err := s.Resume(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Shards) <= interruptAfter {
+		t.Fatalf("upload has %d shards, want more than %d", len(res.Shards), interruptAfter)
+	}
+	if !cidsEqual(first.blobs, res.Shards[:interruptAfter]) {
+		t.Errorf("first run stored %v, want %v", first.blobs, res.Shards[:interruptAfter])
+	}
+	want := append(append([]ipfscid.Cid{}, res.Shards[interruptAfter:]...), res.Index)
+	if !cidsEqual(second.blobs, want) {
+		t.Errorf("resume stored %v, want the remaining shards and the index %v", second.blobs, want)
+	}
+	if !cidsEqual(second.indexes, []ipfscid.Cid{res.Index}) {
+		t.Errorf("resume added indexes %v, want %s", second.indexes, res.Index)
+	}
+	if !second.root.Equals(res.Root) || !cidsEqual(second.shards, res.Shards) {
+		t.Errorf("registered %s with %v, want %s with %v", second.root, second.shards, res.Root, res.Shards)
+	}
+	if HasJournal(dir) {
+		t.Error("journal kept after the upload was registered")
+	}
+
+	// the resumed upload is the one an uninterrupted upload makes
+	svc := &fakeService{failAfter: -1}
+	uninterrupted, err := testSpace(t, svc).UploadDirectory(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uninterrupted.Root.Equals(res.Root) || !cidsEqual(uninterrupted.Shards, res.Shards) {
+		t.Errorf("resumed upload is %s %v, uninterrupted one %s %v", res.Root, res.Shards, uninterrupted.Root, uninterrupted.Shards)
+	}
+}
+
+func TestResumeRefusesSync(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := testSource(t)
+	opts := unixfs.DirOptions{Params: unixfs.DefaultParams()}
+	produce := importDirectory(dir, opts)
+
+	first := &fakeService{failAfter: 1}
+	s := testSpace(t, first)
+	if _, err := s.UploadChanges(context.Background(), dir, opts, produce); err == nil {
+		t.Fatal("upload succeeded despite the failing service")
+	}
+
+	second := &fakeService{failAfter: -1}
+	s.service = second
+	if _, err := s.Resume(context.Background(), dir); err == nil || !strings.Contains(err.Error(), "sync") {
+		t.Fatalf("Resume of an interrupted sync returned %v, want an error pointing to sync", err)
+	}
+	if len(second.blobs) != 0 {
+		t.Fatalf("refused resume stored %v", second.blobs)
+	}
+
+	// running the same sync again skips the shard already stored
+	res, err := s.UploadChanges(context.Background(), dir, opts, produce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]ipfscid.Cid{}, res.Shards[1:]...), res.Index)
+	if !cidsEqual(second.blobs, want) {
+		t.Errorf("second sync stored %v, want %v", second.blobs, want)
+	}
+}
+
+func cidsEqual(a, b []ipfscid.Cid) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// Prefer decides which side wins when a path changed both locally and
+// remotely since the last sync
+type Prefer string
+
+const (
+	// PreferNone keeps both versions: the local file stays in place and the
+	// remote one is written next to it as a conflict copy
+	PreferNone   Prefer = ""
+	PreferLocal  Prefer = "local"
+	PreferRemote Prefer = "remote"
+)
+
+// ParsePrefer validates a --prefer value
+func ParsePrefer(s string) (Prefer, error) {
+	switch p := Prefer(s); p {
+	case PreferNone, PreferLocal, PreferRemote:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid preference %q: must be %s or %s", s, PreferLocal, PreferRemote)
+}
+
+// ErrNotThisTree is returned by CheckRemote for a remote root that is not
+// known to be a version of the synced tree
+var ErrNotThisTree = errors.New("is not known to be a version of this tree")
+
+// CheckRemote reports whether Pull may merge root into the tree st records.
+// A space holds every upload made to it, of this tree or of any other, and
+// merging an unrelated tree would delete every recorded file it lacks. So
+// once paths were recorded, only the recorded root or one the user named
+// explicitly is merged; the latest upload to a space is not taken on trust.
+func (st *State) CheckRemote(root ipfscid.Cid, named bool) error {
+	if len(st.Entries) == 0 || named || root.Equals(st.Root) {
+		return nil
+	}
+	return fmt.Errorf("%s %w", root, ErrNotThisTree)
+}
+
+// Conflict is a path that changed on both sides, and how it was resolved
+type Conflict struct {
+	Path       string
+	Local      ChangeKind
+	Remote     ChangeKind
+	Resolution string
+}
+
+// conflictCopySuffix is appended, with a timestamp, to the name of the
+// remote version of a conflicting file
+const conflictCopySuffix = ".conflict-"
+
+// resolveFile settles a file that changed on both sides. have is the CID
+// of the local content, undefined if the file was deleted locally.
+func (p *puller) resolveFile(c Conflict, local string, info fs.FileInfo, have ipfscid.Cid, e fuse.FileEntry) error {
+	remote, err := ipfscid.Decode(e.CID)
+	if err != nil {
+		return err
+	}
+	// the remote version is the new base; the local one differs from it
+	keepLocal := func() {
+		if !p.opts.DryRun {
+			base := Entry{CID: remote, Tsize: e.Tsize}
+			p.next.Entries[c.Path] = base.rehashed(info.Size(), info.ModTime().UnixNano(), have)
+		}
+	}
+
+	switch {
+	case c.Local == Deleted && p.opts.Prefer == PreferLocal:
+		c.Resolution = "kept local deletion"
+		if !p.opts.DryRun {
+			p.next.Entries[c.Path] = Entry{CID: remote, Tsize: e.Tsize, Size: int64(e.Size)}
+		}
+	case c.Local == Deleted:
+		c.Resolution = "restored remote version"
+		if err := p.fetch(c.Path, local, e, Added); err != nil {
+			return err
+		}
+	case p.opts.Prefer == PreferLocal:
+		c.Resolution = "kept local version"
+		keepLocal()
+	case p.opts.Prefer == PreferRemote:
+		c.Resolution = "replaced local version with remote"
+		if err := p.fetch(c.Path, local, e, Modified); err != nil {
+			return err
+		}
+	default:
+		copyRel := c.Path + conflictCopySuffix + p.stamp
+		c.Resolution = "kept local version, remote saved as " + copyRel
+		p.changes = append(p.changes, Change{Path: copyRel, Kind: Added})
+		if !p.opts.DryRun {
+			if err := p.download(filepath.Join(p.dir, filepath.FromSlash(copyRel)), e); err != nil {
+				return fmt.Errorf("pull %s: %w", copyRel, err)
+			}
+		}
+		keepLocal()
+	}
+	p.conflicts = append(p.conflicts, c)
+	return nil
+}
+
+// resolveDeleted settles a file deleted remotely but modified locally
+func (p *puller) resolveDeleted(rel, local string) error {
+	c := Conflict{Path: rel, Local: Modified, Remote: Deleted, Resolution: "kept local version"}
+	if p.opts.Prefer == PreferRemote {
+		c.Resolution = "deleted local version"
+		p.changes = append(p.changes, Change{Path: rel, Kind: Deleted})
+		if !p.opts.DryRun {
+			if err := os.Remove(local); err != nil {
+				return err
+			}
+		}
+	}
+	// either way the path is no longer synced; a kept file is new locally
+	if !p.opts.DryRun {
+		delete(p.next.Entries, rel)
+	}
+	p.conflicts = append(p.conflicts, c)
+	return nil
+}
+
+// typeConflict records a path that is a file on one side and a directory
+// on the other; the local one is always kept
+func (p *puller) typeConflict(rel string, localDir bool) {
+	res := "kept local file, remote directory not pulled"
+	if localDir {
+		res = "kept local directory, remote file not pulled"
+	}
+	p.conflicts = append(p.conflicts, Conflict{Path: rel, Local: Added, Remote: Added, Resolution: res})
+}
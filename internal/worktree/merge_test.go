@@ -0,0 +1,302 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ipfscid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+func testCID(t *testing.T, data string) ipfscid.Cid {
+	t.Helper()
+	c, err := ipfscid.V1Builder{Codec: ipfscid.Raw, MhType: multihash.SHA2_256}.Sum([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCheckRemote(t *testing.T) {
+	synced, other := testCID(t, "synced root"), testCID(t, "other root")
+	recorded := &State{Root: synced, Entries: map[string]Entry{"a.txt": {CID: testCID(t, "a")}}}
+	// state sync keeps when the tree was synced to another space
+	unrooted := &State{Entries: recorded.Entries}
+	empty := &State{Entries: map[string]Entry{}}
+
+	tests := []struct {
+		name  string
+		st    *State
+		root  ipfscid.Cid
+		named bool
+		ok    bool
+	}{
+		{"never synced", empty, other, false, true},
+		{"synced root", recorded, synced, false, true},
+		{"latest upload of the space", recorded, other, false, false},
+		{"named by the user", recorded, other, true, true},
+		{"records without a root", unrooted, other, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.st.CheckRemote(tt.root, tt.named)
+			if tt.ok && err != nil {
+				t.Fatalf("CheckRemote: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrNotThisTree) {
+				t.Fatalf("CheckRemote = %v, want ErrNotThisTree", err)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if strings.HasSuffix(rel, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func removeFiles(t *testing.T, dir string, rels ...string) {
+	t.Helper()
+	for _, rel := range rels {
+		if err := os.RemoveAll(filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func merged(base map[string]string, changes map[string]string, removed ...string) map[string]string {
+	out := make(map[string]string, len(base)+len(changes))
+	for p, data := range base {
+		out[p] = data
+	}
+	for p, data := range changes {
+		out[p] = data
+	}
+	for _, p := range removed {
+		delete(out, p)
+	}
+	return out
+}
+
+func TestPullMerge(t *testing.T) {
+	base := map[string]string{
+		"a.txt":   "alpha",
+		"b.txt":   "bravo",
+		"d/c.txt": "charlie",
+	}
+	local := merged(base, map[string]string{"d/": ""})
+
+	tests := []struct {
+		name      string
+		local     func(t *testing.T, dir string) // changes made since the sync
+		remote    map[string]string
+		prefer    Prefer
+		dryRun    bool
+		want      map[string]string
+		changes   []Change
+		conflicts []Conflict
+	}{
+		{
+			name:    "remote only",
+			remote:  merged(base, map[string]string{"a.txt": "alpha 2", "e.txt": "echo"}, "b.txt"),
+			want:    merged(local, map[string]string{"a.txt": "alpha 2", "e.txt": "echo"}, "b.txt"),
+			changes: []Change{{Path: "a.txt", Kind: Modified}, {Path: "b.txt", Kind: Deleted}, {Path: "e.txt", Kind: Added}},
+		},
+		{
+			name: "local only",
+			local: func(t *testing.T, dir string) {
+				writeFiles(t, dir, map[string]string{"a.txt": "alpha local", "n.txt": "new"})
+				removeFiles(t, dir, "b.txt")
+			},
+			remote: base,
+			want:   merged(local, map[string]string{"a.txt": "alpha local", "n.txt": "new"}, "b.txt"),
+		},
+		{
+			name:    "remote directory added",
+			remote:  merged(base, map[string]string{"x/y/z.txt": "zulu"}),
+			want:    merged(local, map[string]string{"x/": "", "x/y/": "", "x/y/z.txt": "zulu"}),
+			changes: []Change{{Path: "x", Kind: Added, Dir: true}, {Path: "x/y", Kind: Added, Dir: true}, {Path: "x/y/z.txt", Kind: Added}},
+		},
+		{
+			name:      "both modified",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			want:      merged(local, map[string]string{"a.txt": "alpha local", "a.txt.conflict-*": "alpha remote"}),
+			changes:   []Change{{Path: "a.txt.conflict-*", Kind: Added}},
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Modified, Resolution: "kept local version, remote saved as a.txt.conflict-*"}},
+		},
+		{
+			name:      "both modified, prefer local",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			prefer:    PreferLocal,
+			want:      merged(local, map[string]string{"a.txt": "alpha local"}),
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Modified, Resolution: "kept local version"}},
+		},
+		{
+			name:      "both modified, prefer remote",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			prefer:    PreferRemote,
+			want:      merged(local, map[string]string{"a.txt": "alpha remote"}),
+			changes:   []Change{{Path: "a.txt", Kind: Modified}},
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Modified, Resolution: "replaced local version with remote"}},
+		},
+		{
+			name:   "both modified alike",
+			local:  func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha 2"}) },
+			remote: merged(base, map[string]string{"a.txt": "alpha 2"}),
+			want:   merged(local, map[string]string{"a.txt": "alpha 2"}),
+		},
+		{
+			name:      "both added",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"n.txt": "new local"}) },
+			remote:    merged(base, map[string]string{"n.txt": "new remote"}),
+			want:      merged(local, map[string]string{"n.txt": "new local", "n.txt.conflict-*": "new remote"}),
+			changes:   []Change{{Path: "n.txt.conflict-*", Kind: Added}},
+			conflicts: []Conflict{{Path: "n.txt", Local: Added, Remote: Added, Resolution: "kept local version, remote saved as n.txt.conflict-*"}},
+		},
+		{
+			name:      "deleted locally, modified remotely",
+			local:     func(t *testing.T, dir string) { removeFiles(t, dir, "a.txt") },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			want:      merged(local, map[string]string{"a.txt": "alpha remote"}),
+			changes:   []Change{{Path: "a.txt", Kind: Added}},
+			conflicts: []Conflict{{Path: "a.txt", Local: Deleted, Remote: Modified, Resolution: "restored remote version"}},
+		},
+		{
+			name:      "deleted locally, modified remotely, prefer local",
+			local:     func(t *testing.T, dir string) { removeFiles(t, dir, "a.txt") },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			prefer:    PreferLocal,
+			want:      merged(local, nil, "a.txt"),
+			conflicts: []Conflict{{Path: "a.txt", Local: Deleted, Remote: Modified, Resolution: "kept local deletion"}},
+		},
+		{
+			name:      "modified locally, deleted remotely",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, nil, "a.txt"),
+			want:      merged(local, map[string]string{"a.txt": "alpha local"}),
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Deleted, Resolution: "kept local version"}},
+		},
+		{
+			name:      "modified locally, deleted remotely, prefer remote",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, nil, "a.txt"),
+			prefer:    PreferRemote,
+			want:      merged(local, nil, "a.txt"),
+			changes:   []Change{{Path: "a.txt", Kind: Deleted}},
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Deleted, Resolution: "deleted local version"}},
+		},
+		{
+			name:      "remote directory over local file",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"f": "file"}) },
+			remote:    merged(base, map[string]string{"f/x.txt": "x-ray"}),
+			want:      merged(local, map[string]string{"f": "file"}),
+			conflicts: []Conflict{{Path: "f", Local: Added, Remote: Added, Resolution: "kept local file, remote directory not pulled"}},
+		},
+		{
+			name:      "remote file over local directory",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"g/y.txt": "yankee"}) },
+			remote:    merged(base, map[string]string{"g": "golf"}),
+			want:      merged(local, map[string]string{"g/": "", "g/y.txt": "yankee"}),
+			conflicts: []Conflict{{Path: "g", Local: Added, Remote: Added, Resolution: "kept local directory, remote file not pulled"}},
+		},
+		{
+			name:    "remote directory deleted around an untracked file",
+			local:   func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"d/u.txt": "uniform"}) },
+			remote:  merged(base, nil, "d/c.txt"),
+			want:    merged(local, map[string]string{"d/u.txt": "uniform"}, "d/c.txt"),
+			changes: []Change{{Path: "d/c.txt", Kind: Deleted}},
+		},
+		{
+			name:    "dry run",
+			remote:  merged(base, map[string]string{"a.txt": "alpha 2", "e.txt": "echo"}, "b.txt", "d/c.txt"),
+			dryRun:  true,
+			want:    local,
+			changes: []Change{{Path: "a.txt", Kind: Modified}, {Path: "b.txt", Kind: Deleted}, {Path: "d", Kind: Deleted, Dir: true}, {Path: "d/c.txt", Kind: Deleted}, {Path: "e.txt", Kind: Added}},
+		},
+		{
+			name:      "dry run with a conflict",
+			local:     func(t *testing.T, dir string) { writeFiles(t, dir, map[string]string{"a.txt": "alpha local"}) },
+			remote:    merged(base, map[string]string{"a.txt": "alpha remote"}),
+			dryRun:    true,
+			want:      merged(local, map[string]string{"a.txt": "alpha local"}),
+			changes:   []Change{{Path: "a.txt.conflict-*", Kind: Added}},
+			conflicts: []Conflict{{Path: "a.txt", Local: Modified, Remote: Modified, Resolution: "kept local version, remote saved as a.txt.conflict-*"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			dir := t.TempDir()
+			opts := PullOptions{Params: client.params}
+
+			// the last sync: base pulled into an empty directory
+			prev, changes, conflicts, err := Pull(client, client.tree(t, base), dir, &State{Entries: make(map[string]Entry)}, opts)
+			if err != nil {
+				t.Fatalf("initial Pull: %v", err)
+			}
+			if len(changes) != len(local) || len(conflicts) != 0 {
+				t.Fatalf("initial Pull: %d changes and %d conflicts, want %d and none", len(changes), len(conflicts), len(local))
+			}
+			if tt.local != nil {
+				tt.local(t, dir)
+			}
+
+			root := client.tree(t, tt.remote)
+			opts.Prefer, opts.DryRun = tt.prefer, tt.dryRun
+			next, changes, conflicts, err := Pull(client, root, dir, prev, opts)
+			if err != nil {
+				t.Fatalf("Pull: %v", err)
+			}
+			if got := readFiles(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+			for i := range changes {
+				changes[i].Path = unstamp(changes[i].Path)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %v, want %v", changes, tt.changes)
+			}
+			for i := range conflicts {
+				conflicts[i].Resolution = unstamp(conflicts[i].Resolution)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("conflicts = %v, want %v", conflicts, tt.conflicts)
+			}
+			if tt.dryRun {
+				return
+			}
+
+			// the recorded state is settled: pulling the same root again
+			// changes nothing
+			_, changes, _, err = Pull(client, root, dir, next, opts)
+			if err != nil {
+				t.Fatalf("second Pull: %v", err)
+			}
+			if len(changes) != 0 {
+				t.Errorf("second Pull changed %v", changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// PullOptions controls which remote paths Pull writes
+type PullOptions struct {
+	// Params are the import settings of the tree, used to hash local files
+	// that changed since they were recorded
+	Params unixfs.Params
+	// Hidden pulls entries whose name starts with "."
+	Hidden bool
+	// Paths limits the pull to these slash-separated paths, the paths below
+	// them and the paths matching them as path.Match patterns; empty pulls all
+	Paths []string
+	// DryRun reports the changes without touching dir or its state
+	DryRun bool
+	// Prefer resolves paths changed on both sides since the last sync
+	Prefer Prefer
+}
+
+// Pull merges the UnixFS tree at root into dir, three ways against the
+// version recorded in prev. Paths changed only remotely are updated, paths
+// changed only locally are left alone, and paths changed on both sides are
+// resolved as opts.Prefer says. Files whose recorded CID matches the remote
+// one are not fetched. It returns the state to record, the paths it changed
+// (or would change, with DryRun) in path order, and every conflict with its
+// resolution.
+func Pull(client fuse.StorachaClient, root ipfscid.Cid, dir string, prev *State, opts PullOptions) (*State, []Change, []Conflict, error) {
+	remote := make(map[string]fuse.FileEntry)
+	if err := listTree(client, root.String(), "", opts.Hidden, remote); err != nil {
+		return nil, nil, nil, err
+	}
+
+	next := &State{Space: prev.Space, Root: prev.Root, Params: opts.Params, Hidden: opts.Hidden, Entries: make(map[string]Entry, len(remote))}
+	if len(opts.Paths) == 0 {
+		next.Root = root
+	}
+	for p, e := range prev.Entries {
+		next.Entries[p] = e
+	}
+	p := &puller{
+		client:  client,
+		dir:     dir,
+		opts:    opts,
+		prev:    prev,
+		next:    next,
+		blocked: make(map[string]bool),
+		stamp:   time.Now().Format("20060102T150405"),
+	}
+
+	// parents sort before their children, so directories are created first;
+	// the directories above a selected path are pulled to hold it
+	wanted := make(map[string]bool)
+	for rel := range remote {
+		if !p.selected(rel) {
+			continue
+		}
+		for d := rel; d != "." && !wanted[d]; d = path.Dir(d) {
+			wanted[d] = true
+		}
+	}
+	paths := make([]string, 0, len(wanted))
+	for rel := range wanted {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		if err := p.pullEntry(rel, remote[rel]); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// children sort after their parents, so deleting in reverse empties
+	// directories before removing them
+	var gone []string
+	for rel := range prev.Entries {
+		if _, ok := remote[rel]; !ok && p.selected(rel) {
+			gone = append(gone, rel)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(gone)))
+	for _, rel := range gone {
+		if err := p.deleteEntry(rel); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// writing files bumped the modification time of their directories
+	if !opts.DryRun {
+		for i := len(paths) - 1; i >= 0; i-- {
+			if e := remote[paths[i]]; e.Dir {
+				p.applyMetadata(filepath.Join(dir, filepath.FromSlash(paths[i])), e)
+				p.record(paths[i], e)
+			}
+		}
+	}
+
+	sort.Slice(p.changes, func(i, j int) bool { return p.changes[i].Path < p.changes[j].Path })
+	sort.Slice(p.conflicts, func(i, j int) bool { return p.conflicts[i].Path < p.conflicts[j].Path })
+	return next, p.changes, p.conflicts, nil
+}
+
+type puller struct {
+	client    fuse.StorachaClient
+	dir       string
+	opts      PullOptions
+	prev      *State
+	next      *State
+	changes   []Change
+	conflicts []Conflict
+	blocked   map[string]bool // remote directories that could not be created
+	stamp     string          // timestamp of conflict copies
+}
+
+// listTree adds every entry below the directory cid to out by path
+func listTree(client fuse.StorachaClient, cid, dir string, hidden bool, out map[string]fuse.FileEntry) error {
+	entries, err := client.ListDir(cid)
+	if err != nil {
+		return fmt.Errorf("list /%s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if e.Name == unixfs.StateDir || (!hidden && strings.HasPrefix(e.Name, ".")) {
+			continue
+		}
+		rel := path.Join(dir, e.Name)
+		out[rel] = e
+		if e.Dir {
+			if err := listTree(client, e.CID, rel, hidden, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// selected reports whether rel is covered by the path filters
+func (p *puller) selected(rel string) bool {
+	if len(p.opts.Paths) == 0 {
+		return true
+	}
+	for _, f := range p.opts.Paths {
+		f = strings.Trim(f, "/")
+		if f == "" || rel == f || strings.HasPrefix(rel, f+"/") {
+			return true
+		}
+		if ok, _ := path.Match(f, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *puller) pullEntry(rel string, e fuse.FileEntry) error {
+	if p.blocked[path.Dir(rel)] {
+		if e.Dir {
+			p.blocked[rel] = true
+		}
+		return nil
+	}
+	local := filepath.Join(p.dir, filepath.FromSlash(rel))
+	info, err := os.Lstat(local)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+	old, recorded := p.prev.Entries[rel]
+
+	if e.Dir {
+		if exists && info.IsDir() {
+			return nil
+		}
+		if exists {
+			p.typeConflict(rel, false)
+			p.blocked[rel] = true
+			return nil
+		}
+		p.changes = append(p.changes, Change{Path: rel, Kind: Added, Dir: true})
+		if p.opts.DryRun {
+			return nil
+		}
+		return os.MkdirAll(local, 0755)
+	}
+
+	remote, err := ipfscid.Decode(e.CID)
+	if err != nil {
+		return err
+	}
+	base := ipfscid.Undef
+	if recorded && !old.Dir {
+		base = old.CID
+	}
+
+	if !exists {
+		switch {
+		case !base.Defined():
+			return p.fetch(rel, local, e, Added)
+		case base.Equals(remote):
+			// deleted locally only
+			return nil
+		}
+		return p.resolveFile(Conflict{Path: rel, Local: Deleted, Remote: Modified}, local, nil, ipfscid.Undef, e)
+	}
+	if info.IsDir() {
+		p.typeConflict(rel, true)
+		return nil
+	}
+
+	have, err := p.localCID(local, rel, info)
+	if err != nil {
+		return err
+	}
+	switch {
+	case have.Equals(remote):
+		// already up to date, possibly without a record yet
+		if !p.opts.DryRun {
+			p.record(rel, e)
+		}
+		return nil
+	case have.Equals(base):
+		return p.fetch(rel, local, e, Modified)
+	case base.Equals(remote):
+		// changed locally only; remember the hash so the file is not read
+		// again while it stays untouched
+		if !p.opts.DryRun {
+			p.next.Entries[rel] = old.rehashed(info.Size(), info.ModTime().UnixNano(), have)
+		}
+		return nil
+	case !base.Defined():
+		return p.resolveFile(Conflict{Path: rel, Local: Added, Remote: Added}, local, info, have, e)
+	}
+	return p.resolveFile(Conflict{Path: rel, Local: Modified, Remote: Modified}, local, info, have, e)
+}
+
+// fetch writes the remote file e at rel and records it
+func (p *puller) fetch(rel, local string, e fuse.FileEntry, kind ChangeKind) error {
+	p.changes = append(p.changes, Change{Path: rel, Kind: kind})
+	if p.opts.DryRun {
+		return nil
+	}
+	if err := p.download(local, e); err != nil {
+		return fmt.Errorf("pull %s: %w", rel, err)
+	}
+	p.record(rel, e)
+	return nil
+}
+
+// localCID returns the CID of the local file at rel, from its record if it
+// was not touched since it was last hashed, otherwise by importing it again
+func (p *puller) localCID(local, rel string, info fs.FileInfo) (ipfscid.Cid, error) {
+	if e, ok := p.prev.Entries[rel]; ok && e.unchanged(info) {
+		return e.localCID(), nil
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return ipfscid.Undef, err
+	}
+	defer func() { _ = f.Close() }()
+	link, err := unixfs.ImportFile(f, p.opts.Params, func(blocks.Block) error { return nil })
+	if err != nil {
+		return ipfscid.Undef, fmt.Errorf("%s: %w", local, err)
+	}
+	return link.Cid, nil
+}
+
+// download writes the remote file next to local and renames it into place,
+// so an interrupted pull never leaves a partial file behind
+func (p *puller) download(local string, e fuse.FileEntry) error {
+	r, err := p.client.OpenFile(e.CID)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(local), ".storacha-pull-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := io.Copy(tmp, io.NewSectionReader(r, 0, int64(r.Size()))); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if e.Mode == 0 {
+		// CreateTemp makes files only the owner can read
+		e.Mode = 0644
+	}
+	p.applyMetadata(tmp.Name(), e)
+	return os.Rename(tmp.Name(), local)
+}
+
+// applyMetadata sets the UnixFS mode and modification time of e on local
+func (p *puller) applyMetadata(local string, e fuse.FileEntry) {
+	if e.Mode != 0 {
+		if err := os.Chmod(local, e.Mode.Perm()); err != nil {
+			log.Printf("Failed to set mode of %s: %v", local, err)
+		}
+	}
+	if !e.ModTime.IsZero() {
+		if err := os.Chtimes(local, e.ModTime, e.ModTime); err != nil {
+			log.Printf("Failed to set modification time of %s: %v", local, err)
+		}
+	}
+}
+
+// record notes that rel now holds the remote entry e
+func (p *puller) record(rel string, e fuse.FileEntry) {
+	info, err := os.Stat(filepath.Join(p.dir, filepath.FromSlash(rel)))
+	if err != nil || info.IsDir() != e.Dir {
+		return
+	}
+	c, err := ipfscid.Decode(e.CID)
+	if err != nil {
+		return
+	}
+	entry := Entry{CID: c, Dir: e.Dir, Tsize: e.Tsize, ModTime: info.ModTime().UnixNano()}
+	if !e.Dir {
+		entry.Size = info.Size()
+	}
+	p.next.Entries[rel] = entry
+}
+
+// deleteEntry removes a path that is gone remotely; a file changed locally
+// since it was recorded is a conflict
+func (p *puller) deleteEntry(rel string) error {
+	old := p.prev.Entries[rel]
+	local := filepath.Join(p.dir, filepath.FromSlash(rel))
+	info, err := os.Lstat(local)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !p.opts.DryRun {
+			delete(p.next.Entries, rel)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if old.Dir {
+		if !info.IsDir() {
+			return nil
+		}
+		if p.opts.DryRun {
+			p.changes = append(p.changes, Change{Path: rel, Kind: Deleted, Dir: true})
+			return nil
+		}
+		// local files not tracked by the sync keep the directory alive
+		if err := os.Remove(local); err != nil {
+			log.Printf("Not deleting directory %s: %v", rel, err)
+			return nil
+		}
+		delete(p.next.Entries, rel)
+		p.changes = append(p.changes, Change{Path: rel, Kind: Deleted, Dir: true})
+		return nil
+	}
+
+	if info.IsDir() {
+		// replaced by a directory locally, which is new to the remote side
+		if !p.opts.DryRun {
+			delete(p.next.Entries, rel)
+		}
+		return nil
+	}
+	have, err := p.localCID(local, rel, info)
+	if err != nil {
+		return err
+	}
+	if !have.Equals(old.CID) {
+		return p.resolveDeleted(rel, local)
+	}
+	p.changes = append(p.changes, Change{Path: rel, Kind: Deleted})
+	if p.opts.DryRun {
+		return nil
+	}
+	if err := os.Remove(local); err != nil {
+		return err
+	}
+	delete(p.next.Entries, rel)
+	return nil
+}
@@ -0,0 +1,174 @@
+package worktree
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// fakeClient serves trees built in memory with the importer, so remote file
+// CIDs match what hashing the same content locally gives
+type fakeClient struct {
+	fuse.StorachaClient
+	params unixfs.Params
+	dirs   map[string][]fuse.FileEntry
+	files  map[string][]byte
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		params: unixfs.DefaultParams(),
+		dirs:   make(map[string][]fuse.FileEntry),
+		files:  make(map[string][]byte),
+	}
+}
+
+func discard(blocks.Block) error { return nil }
+
+// tree builds a remote tree holding files, keyed by slash-separated path; a
+// key ending in "/" is an empty directory
+func (c *fakeClient) tree(t *testing.T, files map[string]string) ipfscid.Cid {
+	t.Helper()
+	link, err := c.dir(files, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Cid
+}
+
+func (c *fakeClient) dir(files map[string]string, prefix string) (unixfs.Link, error) {
+	children := make(map[string]bool) // name -> is a directory
+	for p := range files {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		name, _, sub := strings.Cut(rest, "/")
+		if name != "" {
+			children[name] = children[name] || sub
+		}
+	}
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var entries []unixfs.DirEntry
+	var listing []fuse.FileEntry
+	for _, name := range names {
+		var (
+			link unixfs.Link
+			err  error
+		)
+		if children[name] {
+			link, err = c.dir(files, prefix+name+"/")
+		} else {
+			data := files[prefix+name]
+			link, err = unixfs.ImportFile(strings.NewReader(data), c.params, discard)
+			c.files[link.Cid.String()] = []byte(data)
+		}
+		if err != nil {
+			return unixfs.Link{}, err
+		}
+		entries = append(entries, unixfs.DirEntry{Name: name, Link: link})
+		listing = append(listing, fuse.FileEntry{Name: name, Dir: children[name], Size: link.Size, CID: link.Cid.String(), Tsize: link.Tsize})
+	}
+	link, err := unixfs.BuildDirectory(entries, c.params, discard)
+	if err != nil {
+		return unixfs.Link{}, err
+	}
+	c.dirs[link.Cid.String()] = listing
+	return link, nil
+}
+
+func (c *fakeClient) ListDir(cid string) ([]fuse.FileEntry, error) {
+	entries, ok := c.dirs[cid]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return entries, nil
+}
+
+func (c *fakeClient) OpenFile(cid string) (fuse.FileReader, error) {
+	data, ok := c.files[cid]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return fileReader{bytes.NewReader(data)}, nil
+}
+
+type fileReader struct{ *bytes.Reader }
+
+func (r fileReader) Size() uint64 { return uint64(r.Reader.Size()) }
+
+// conflictStamp matches the timestamp of conflict copies, which tests
+// replace with "*"
+var conflictStamp = regexp.MustCompile(`\.conflict-\d{8}T\d{6}`)
+
+func unstamp(s string) string {
+	return conflictStamp.ReplaceAllString(s, ".conflict-*")
+}
+
+// readFiles returns the contents of dir by path, directories ending in "/"
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+			return nil
+		case rel == unixfs.StateDir:
+			return filepath.SkipDir
+		case d.IsDir():
+			files[rel+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		files[unstamp(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestPullPaths(t *testing.T) {
+	client := newFakeClient()
+	dir := t.TempDir()
+	root := client.tree(t, map[string]string{"a.txt": "alpha", "d/c.txt": "charlie", "d/e.md": "echo"})
+
+	next, changes, _, err := Pull(client, root, dir, &State{Entries: make(map[string]Entry)}, PullOptions{Params: client.params, Paths: []string{"d/*.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{Path: "d", Kind: Added, Dir: true}, {Path: "d/c.txt", Kind: Added}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	files := map[string]string{"d/": "", "d/c.txt": "charlie"}
+	if got := readFiles(t, dir); !reflect.DeepEqual(got, files) {
+		t.Errorf("files = %v, want %v", got, files)
+	}
+	// a partial pull does not make root the synced version
+	if next.Synced() {
+		t.Errorf("partial pull recorded root %s", next.Root)
+	}
+}
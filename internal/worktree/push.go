@@ -0,0 +1,125 @@
+package worktree
+
+import (
+	"io/fs"
+	"sort"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+)
+
+// ChangeKind says how a path changed
+type ChangeKind byte
+
+const (
+	Added    ChangeKind = 'A'
+	Modified ChangeKind = 'M'
+	Deleted  ChangeKind = 'D'
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Change is one path that differs between two versions of a tree
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Dir  bool
+}
+
+// Push imports dir as the next version after prev. Files whose size and
+// modification time match prev keep their recorded CIDs without being read,
+// and blocks prev already synced are not passed to sink, so sink only sees
+// the new and changed parts of the tree. It returns the state to record
+// once the emitted blocks are stored, and the changed paths in path order.
+func Push(dir string, prev *State, opts unixfs.DirOptions, sink unixfs.BlockSink) (*State, []Change, error) {
+	// recorded CIDs are only valid for the settings they were imported with
+	reuse := prev.Synced() && prev.Params == opts.Params && prev.Hidden == opts.Hidden
+
+	known := make(map[string]struct{}, len(prev.Entries)+1)
+	var cached func(rel string, info fs.FileInfo) (unixfs.Link, bool)
+	if reuse {
+		known[prev.Root.KeyString()] = struct{}{}
+		for _, e := range prev.Entries {
+			known[e.CID.KeyString()] = struct{}{}
+		}
+		cached = func(rel string, info fs.FileInfo) (unixfs.Link, bool) {
+			// files modified since the sync have blocks the space lacks
+			e, ok := prev.Entries[rel]
+			if !ok || !e.unchanged(info) || e.Local.Defined() {
+				return unixfs.Link{}, false
+			}
+			return unixfs.Link{Cid: e.CID, Size: uint64(e.Size), Tsize: e.Tsize}, true
+		}
+	}
+
+	next, err := importTree(dir, prev, opts, cached, func(b blocks.Block) error {
+		if _, ok := known[b.Cid().KeyString()]; ok {
+			return nil
+		}
+		return sink(b)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return next, Diff(prev.Entries, next.Entries), nil
+}
+
+// importTree imports dir with opts, taking the links cached returns instead
+// of reading files, and returns the resulting state
+func importTree(dir string, prev *State, opts unixfs.DirOptions, cached func(string, fs.FileInfo) (unixfs.Link, bool), sink unixfs.BlockSink) (*State, error) {
+	next := &State{Space: prev.Space, Params: opts.Params, Hidden: opts.Hidden, Entries: make(map[string]Entry)}
+	opts.Cached = cached
+	opts.Imported = func(rel string, info fs.FileInfo, link unixfs.Link) {
+		if rel == "" {
+			next.Root = link.Cid
+			return
+		}
+		e := Entry{CID: link.Cid, Dir: info.IsDir(), Tsize: link.Tsize, ModTime: info.ModTime().UnixNano()}
+		if !e.Dir {
+			e.Size = info.Size()
+		}
+		next.Entries[rel] = e
+	}
+	if _, err := unixfs.ImportDirectory(dir, opts, sink); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Diff lists the paths that differ from old to new. Directories are only
+// reported when they appear or disappear; their contents are listed too.
+func Diff(old, new map[string]Entry) []Change {
+	var changes []Change
+	for p, e := range new {
+		o, ok := old[p]
+		switch {
+		case !ok || o.Dir != e.Dir:
+			changes = append(changes, Change{Path: p, Kind: Added, Dir: e.Dir})
+		case !e.Dir && !o.CID.Equals(e.CID):
+			changes = append(changes, Change{Path: p, Kind: Modified})
+		}
+	}
+	for p, o := range old {
+		if e, ok := new[p]; !ok || o.Dir != e.Dir {
+			changes = append(changes, Change{Path: p, Kind: Deleted, Dir: o.Dir})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		// a path that changed type is reported deleted before it is added
+		return changes[i].Kind == Deleted
+	})
+	return changes
+}
@@ -0,0 +1,153 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+)
+
+func TestDiff(t *testing.T) {
+	a, b, c := testCID(t, "a"), testCID(t, "b"), testCID(t, "c")
+	tests := []struct {
+		name     string
+		old, new map[string]Entry
+		want     []Change
+	}{
+		{
+			name: "unchanged",
+			old:  map[string]Entry{"a": {CID: a}, "d": {CID: b, Dir: true}},
+			new:  map[string]Entry{"a": {CID: a}, "d": {CID: b, Dir: true}},
+		},
+		{
+			name: "file added, modified and deleted",
+			old:  map[string]Entry{"a": {CID: a}, "b": {CID: b}},
+			new:  map[string]Entry{"a": {CID: c}, "c": {CID: c}},
+			want: []Change{{Path: "a", Kind: Modified}, {Path: "b", Kind: Deleted}, {Path: "c", Kind: Added}},
+		},
+		{
+			name: "directory contents changed",
+			old:  map[string]Entry{"d": {CID: a, Dir: true}, "d/x": {CID: b}},
+			new:  map[string]Entry{"d": {CID: c, Dir: true}, "d/x": {CID: c}},
+			want: []Change{{Path: "d/x", Kind: Modified}},
+		},
+		{
+			name: "directory added and deleted with its contents",
+			old:  map[string]Entry{"d": {CID: a, Dir: true}, "d/x": {CID: b}},
+			new:  map[string]Entry{"e": {CID: a, Dir: true}, "e/x": {CID: b}},
+			want: []Change{{Path: "d", Kind: Deleted, Dir: true}, {Path: "d/x", Kind: Deleted}, {Path: "e", Kind: Added, Dir: true}, {Path: "e/x", Kind: Added}},
+		},
+		{
+			name: "file replaced by a directory",
+			old:  map[string]Entry{"p": {CID: a}},
+			new:  map[string]Entry{"p": {CID: b, Dir: true}, "p/x": {CID: c}},
+			want: []Change{{Path: "p", Kind: Deleted}, {Path: "p", Kind: Added, Dir: true}, {Path: "p/x", Kind: Added}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// push imports dir after prev and returns the next state, the changes and
+// the number of blocks handed to the sink
+func push(t *testing.T, dir string, prev *State, opts unixfs.DirOptions) (*State, []Change, int) {
+	t.Helper()
+	n := 0
+	next, changes, err := Push(dir, prev, opts, func(blocks.Block) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	return next, changes, n
+}
+
+func TestPushReuse(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.txt": "alpha", "b.txt": "bravo", "d/c.txt": "charlie"})
+	opts := unixfs.DirOptions{Params: unixfs.DefaultParams()}
+
+	first, changes, n := push(t, dir, &State{Entries: make(map[string]Entry)}, opts)
+	want := []Change{{Path: "a.txt", Kind: Added}, {Path: "b.txt", Kind: Added}, {Path: "d", Kind: Added, Dir: true}, {Path: "d/c.txt", Kind: Added}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("first Push changes = %v, want %v", changes, want)
+	}
+	// three raw leaves and two directories
+	if n != 5 {
+		t.Fatalf("first Push emitted %d blocks, want 5", n)
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		next, changes, n := push(t, dir, first, opts)
+		if !next.Root.Equals(first.Root) || len(changes) != 0 || n != 0 {
+			t.Errorf("Push of an unchanged tree: root %s (was %s), changes %v, %d blocks", next.Root, first.Root, changes, n)
+		}
+	})
+
+	t.Run("same size and mtime is not read", func(t *testing.T) {
+		p := filepath.Join(dir, "a.txt")
+		if err := os.WriteFile(p, []byte("ALPHA"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Unix(0, first.Entries["a.txt"].ModTime)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		next, changes, n := push(t, dir, first, opts)
+		if !next.Root.Equals(first.Root) || len(changes) != 0 || n != 0 {
+			t.Errorf("Push read a file whose size and mtime are unchanged: root %s (was %s), changes %v, %d blocks", next.Root, first.Root, changes, n)
+		}
+	})
+
+	t.Run("modified file", func(t *testing.T) {
+		writeFiles(t, dir, map[string]string{"b.txt": "bravo 2"})
+		next, changes, n := push(t, dir, first, opts)
+		want := []Change{{Path: "b.txt", Kind: Modified}}
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("changes = %v, want %v", changes, want)
+		}
+		// the new leaf and the root; d is unchanged
+		if n != 2 {
+			t.Errorf("Push emitted %d blocks, want 2", n)
+		}
+		if next.Entries["d"].CID != first.Entries["d"].CID {
+			t.Errorf("unchanged directory d got a new CID")
+		}
+	})
+
+	t.Run("modified since hashed", func(t *testing.T) {
+		// a file hashed by status after the sync keeps its local CID in the
+		// state, but its blocks were never stored
+		st := *first
+		st.Entries = make(map[string]Entry)
+		for p, e := range first.Entries {
+			st.Entries[p] = e
+		}
+		info, err := os.Stat(filepath.Join(dir, "b.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.Entries["b.txt"] = st.Entries["b.txt"].rehashed(info.Size(), info.ModTime().UnixNano(), testCID(t, "b.txt as hashed"))
+		if _, _, n := push(t, dir, &st, opts); n != 2 {
+			t.Errorf("Push emitted %d blocks, want 2", n)
+		}
+	})
+
+	t.Run("other params", func(t *testing.T) {
+		other := opts
+		other.Params.RawLeaves, other.Params.CIDVersion = false, 0
+		if _, _, n := push(t, dir, first, other); n != 5 {
+			t.Errorf("Push with other params emitted %d blocks, want all 5", n)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+// Package worktree tracks a local directory that is kept in sync with a
+// Storacha space, and works out what changed on either side since the last
+// sync.
+package worktree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ABD-AZE/StorachaFS/internal/atomicfile"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// stateVersion is the schema version of the sync state files Save writes.
+// Files without a version predate versioning and read as version 1.
+const stateVersion = 1
+
+// Entry is what was last synced for one path of the tree. Size and ModTime
+// describe the local file as it was last hashed, so an unchanged file is
+// never read again to find out what it holds.
+type Entry struct {
+	CID     ipfscid.Cid `json:"cid"` // synced remote CID
+	Dir     bool        `json:"dir,omitempty"`
+	Size    int64       `json:"size"`
+	Tsize   uint64      `json:"tsize"` // DAG size, as recorded in parent links
+	ModTime int64       `json:"mtime"` // local modification time, unix nanoseconds
+	// Local is the CID of the local content when it was modified after the
+	// sync; undefined while the local file still holds CID
+	Local ipfscid.Cid `json:"local,omitempty"`
+}
+
+// unchanged reports whether the local file described by info is the one
+// the entry was last hashed as
+func (e Entry) unchanged(info fs.FileInfo) bool {
+	return !e.Dir && !info.IsDir() && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano()
+}
+
+// localCID is the CID of the local content as last hashed
+func (e Entry) localCID() ipfscid.Cid {
+	if e.Local.Defined() {
+		return e.Local
+	}
+	return e.CID
+}
+
+// rehashed returns e updated to record that the local file, now of size
+// and modification time mtime, holds have
+func (e Entry) rehashed(size, mtime int64, have ipfscid.Cid) Entry {
+	e.Size, e.ModTime, e.Local = size, mtime, ipfscid.Undef
+	if !have.Equals(e.CID) {
+		e.Local = have
+	}
+	return e
+}
+
+// State is the last synced version of a working tree
+type State struct {
+	Version  int              `json:"version"`
+	Space    string           `json:"space"`
+	Root     ipfscid.Cid      `json:"root"`
+	Params   unixfs.Params    `json:"params"`
+	Hidden   bool             `json:"hidden"`
+	SyncedAt time.Time        `json:"syncedAt"`
+	Entries  map[string]Entry `json:"entries"` // by slash-separated path, "" excluded
+}
+
+// StatePath is where the sync state of dir is recorded
+func StatePath(dir string) string {
+	return filepath.Join(dir, unixfs.StateDir, "sync.json")
+}
+
+// LoadState reads the sync state of dir; a tree that was never synced has
+// an empty state with an undefined Root
+func LoadState(dir string) (*State, error) {
+	data, err := os.ReadFile(StatePath(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{Version: stateVersion, Entries: make(map[string]Entry)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &State{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", StatePath(dir), err)
+	}
+	if st.Version > stateVersion {
+		return nil, fmt.Errorf("%s has schema version %d; this storachafs reads up to %d", StatePath(dir), st.Version, stateVersion)
+	}
+	st.Version = stateVersion
+	if st.Entries == nil {
+		st.Entries = make(map[string]Entry)
+	}
+	return st, nil
+}
+
+// Save replaces the sync state of dir with st atomically, so a crash never
+// leaves it half written
+func (st *State) Save(dir string) error {
+	st.Version = stateVersion
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(StatePath(dir)), 0755); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(StatePath(dir), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write sync state: %w", err)
+	}
+	return nil
+}
+
+// Synced reports whether st records a previous sync
+func (st *State) Synced() bool {
+	return st.Root.Defined()
+}
@@ -0,0 +1,59 @@
+package worktree
+
+import (
+	"io/fs"
+
+	"github.com/ABD-AZE/StorachaFS/internal/fuse"
+	"github.com/ABD-AZE/StorachaFS/internal/unixfs"
+	blocks "github.com/ipfs/go-block-format"
+	ipfscid "github.com/ipfs/go-cid"
+)
+
+// LocalChanges lists the paths of dir that changed since the sync recorded
+// in st. Only files whose size or modification time changed since they were
+// last hashed are read; their new hashes are cached in st, so saving it
+// spares the next call from reading them again.
+func LocalChanges(dir string, st *State, opts unixfs.DirOptions) ([]Change, error) {
+	cached := func(rel string, info fs.FileInfo) (unixfs.Link, bool) {
+		e, ok := st.Entries[rel]
+		if !ok || !e.unchanged(info) {
+			return unixfs.Link{}, false
+		}
+		return unixfs.Link{Cid: e.localCID(), Size: uint64(e.Size), Tsize: e.Tsize}, true
+	}
+	next, err := importTree(dir, st, opts, cached, func(blocks.Block) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	changes := Diff(st.Entries, next.Entries)
+
+	for p, e := range next.Entries {
+		old, ok := st.Entries[p]
+		if !ok || old.Dir || e.Dir {
+			continue
+		}
+		st.Entries[p] = old.rehashed(e.Size, e.ModTime, e.CID)
+	}
+	return changes, nil
+}
+
+// RemoteChanges lists the paths that differ between the tree recorded in st
+// and the remote tree at root
+func RemoteChanges(client fuse.StorachaClient, root ipfscid.Cid, st *State) ([]Change, error) {
+	if root.Equals(st.Root) {
+		return nil, nil
+	}
+	remote := make(map[string]fuse.FileEntry)
+	if err := listTree(client, root.String(), "", st.Hidden, remote); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]Entry, len(remote))
+	for p, e := range remote {
+		c, err := ipfscid.Decode(e.CID)
+		if err != nil {
+			return nil, err
+		}
+		entries[p] = Entry{CID: c, Dir: e.Dir, Size: int64(e.Size), Tsize: e.Tsize}
+	}
+	return Diff(st.Entries, entries), nil
+}